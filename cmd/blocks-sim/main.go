@@ -0,0 +1,187 @@
+// Command blocks-sim plays games with a bot strategy and reports how they went, so changes to piece sets or scoring
+// can be measured before they ship.
+//
+//	blocks-sim -strategy greedy -seeds 1-1000 -scoring combo
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mikecoop83/blocks/lib"
+)
+
+// result is the outcome of a single simulated game.
+type result struct {
+	seed  uint64
+	score int64
+	moves int
+	lines int
+	// finished is false if the game was stopped at the move limit.
+	finished bool
+	// deathPieces are the names of the pieces left in the tray when the game ended.
+	deathPieces []string
+}
+
+func main() {
+	var (
+		seedsFlag    = flag.String("seeds", "1-100", "game IDs to play, as comma-separated IDs or ranges like 1-1000")
+		strategyFlag = flag.String("strategy", "greedy", "bot strategy: "+strings.Join(strategyNames, ", "))
+		sizeFlag     = flag.String("size", "8x8", "board size as WIDTHxHEIGHT")
+		scoringFlag  = flag.String("scoring", lib.ScoringClassic.String(), "scoring rules")
+		piecesFlag   = flag.String("pieces", lib.ClassicPieceSet, "piece set name, or a path to a piece set file")
+		distFlag     = flag.String("distribution", lib.DistributionUniform.String(), "piece distribution")
+		rotateFlag   = flag.Bool("rotate", false, "let the bot rotate pieces")
+		colorFlag    = flag.Bool("color-bonus", false, "score a bonus for clearing lines of a single color")
+		boxesFlag    = flag.Bool("boxes", false, "clear full 3x3 boxes as well as full lines")
+		versionFlag  = flag.Int("version", int(lib.CurrentRulesVersion), "rules version that turns game IDs into pieces")
+		maxMoves     = flag.Int("max-moves", 5000, "stop a game that hasn't ended after this many moves")
+		workers      = flag.Int("workers", runtime.NumCPU(), "number of games to play at once")
+	)
+	flag.Parse()
+
+	seeds, err := parseSeeds(*seedsFlag)
+	if err != nil {
+		log.Fatalf("invalid -seeds: %v", err)
+	}
+	config, err := parseConfig(*sizeFlag, *scoringFlag, *piecesFlag, *distFlag, *rotateFlag, *boxesFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	config.Version = lib.RulesVersion(*versionFlag)
+	config.ColorBonus = *colorFlag
+	if _, err := config.Version.NewSource(0); err != nil {
+		log.Fatal(err)
+	}
+	if _, err := newStrategy(*strategyFlag, 0); err != nil {
+		log.Fatal(err)
+	}
+
+	results := make([]result, len(seeds))
+	var wg sync.WaitGroup
+	next := make(chan int)
+	for range max(*workers, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				r, err := play(seeds[i], config, *strategyFlag, *maxMoves)
+				if err != nil {
+					log.Fatalf("game %d: %v", seeds[i], err)
+				}
+				results[i] = r
+			}
+		}()
+	}
+	for i := range seeds {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	fmt.Printf(
+		"strategy %s, %d games, board %dx%d, scoring %s, pieces %s, distribution %s, rotation %t, color bonus %t, "+
+			"boxes %t\n\n",
+		*strategyFlag, len(results), config.Width, config.Height, config.Scoring, *piecesFlag, config.Distribution,
+		config.PlayerRotation, config.ColorBonus, config.Boxes)
+	report(os.Stdout, results)
+}
+
+// parseSeeds parses comma-separated game IDs and inclusive ranges of them.
+func parseSeeds(text string) ([]uint64, error) {
+	var seeds []uint64
+	for _, part := range strings.Split(text, ",") {
+		fromText, toText, isRange := strings.Cut(strings.TrimSpace(part), "-")
+		from, err := strconv.ParseUint(fromText, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		to := from
+		if isRange {
+			to, err = strconv.ParseUint(toText, 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			if to < from {
+				return nil, fmt.Errorf("range %s is backwards", part)
+			}
+		}
+		for seed := from; seed <= to; seed++ {
+			seeds = append(seeds, seed)
+			if seed == to {
+				break
+			}
+		}
+	}
+	return seeds, nil
+}
+
+func parseConfig(size, scoring, pieces, distribution string, rotate, boxes bool) (lib.Config, error) {
+	config := lib.Config{PlayerRotation: rotate, Boxes: boxes}
+	if _, err := fmt.Sscanf(size, "%dx%d", &config.Width, &config.Height); err != nil {
+		return lib.Config{}, fmt.Errorf("invalid -size %q: %w", size, err)
+	}
+	if scoring != lib.ScoringClassic.String() {
+		config.Scoring = lib.ScoringRules(scoring)
+	}
+	if distribution != lib.DistributionUniform.String() {
+		config.Distribution = lib.PieceDistribution(distribution)
+	}
+	if pieces != lib.ClassicPieceSet {
+		if _, err := lib.LookupPieceSet(pieces); err != nil {
+			set, err := lib.ReadPieceSetFile(pieces)
+			if err != nil {
+				return lib.Config{}, err
+			}
+			if err := lib.RegisterPieceSet(set); err != nil {
+				return lib.Config{}, err
+			}
+			pieces = set.Name
+		}
+		config.Pieces = pieces
+	}
+	// Check the config by starting a game with it.
+	if _, err := lib.NewSession(0, config); err != nil {
+		return lib.Config{}, err
+	}
+	return config, nil
+}
+
+// play plays the game with the seed until it ends or reaches the move limit.
+func play(seed uint64, config lib.Config, strategyName string, maxMoves int) (result, error) {
+	s, err := lib.NewSession(seed, config)
+	if err != nil {
+		return result{}, err
+	}
+	choose, err := newStrategy(strategyName, seed)
+	if err != nil {
+		return result{}, err
+	}
+	r := result{seed: seed}
+	for !s.GameOver() && len(s.Moves()) < maxMoves {
+		for _, move := range choose(s) {
+			placement, err := s.Play(move)
+			if err != nil {
+				return result{}, fmt.Errorf("move %d: %w", len(s.Moves()), err)
+			}
+			r.lines += placement.NumClearedLines()
+		}
+	}
+	r.score = s.Score()
+	r.moves = len(s.Moves())
+	r.finished = s.GameOver()
+	if r.finished {
+		for _, piece := range s.Tray() {
+			if piece != nil {
+				r.deathPieces = append(r.deathPieces, piece.Name)
+			}
+		}
+	}
+	return r, nil
+}
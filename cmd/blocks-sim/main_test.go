@@ -0,0 +1,18 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSeeds(t *testing.T) {
+	seeds, err := parseSeeds("3, 7-9,1")
+	require.NoError(t, err)
+	require.Equal(t, []uint64{3, 7, 8, 9, 1}, seeds)
+
+	for _, text := range []string{"", "5-2", "a-3", "1-"} {
+		_, err := parseSeeds(text)
+		require.Error(t, err, text)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/mikecoop83/blocks/lib"
+)
+
+const (
+	histogramBuckets = 10
+	histogramWidth   = 40
+)
+
+// report writes the score distribution, game lengths, line clears and death causes of the results.
+func report(w io.Writer, results []result) {
+	if len(results) == 0 {
+		fmt.Fprintln(w, "no games played")
+		return
+	}
+	scores := make([]int64, len(results))
+	var totalScore int64
+	var totalMoves, totalLines, unfinished int
+	best := results[0]
+	deaths := make(map[string]int)
+	for i, r := range results {
+		if r.score > best.score {
+			best = r
+		}
+		scores[i] = r.score
+		totalScore += r.score
+		totalMoves += r.moves
+		totalLines += r.lines
+		if !r.finished {
+			unfinished++
+		}
+		// Count each shape once per game, however many of it were left.
+		seen := make(map[string]bool)
+		for _, name := range r.deathPieces {
+			if !seen[name] {
+				seen[name] = true
+				deaths[name]++
+			}
+		}
+	}
+	slices.Sort(scores)
+	games := float64(len(results))
+
+	fmt.Fprintf(w, "score: mean %.1f, min %d, p10 %d, median %d, p90 %d, max %d\n",
+		float64(totalScore)/games, scores[0], percentile(scores, 10), percentile(scores, 50), percentile(scores, 90),
+		scores[len(scores)-1])
+	fmt.Fprintf(w, "best game: %d (%s)\n", best.seed, lib.FormatGameID(best.seed))
+	fmt.Fprintf(w, "moves per game: %.1f\n", float64(totalMoves)/games)
+	fmt.Fprintf(w, "lines per game: %.2f\n", float64(totalLines)/games)
+	if unfinished > 0 {
+		fmt.Fprintf(w, "unfinished: %d games stopped at the move limit\n", unfinished)
+	}
+
+	fmt.Fprintln(w, "\nscore distribution:")
+	writeHistogram(w, scores)
+
+	fmt.Fprintln(w, "\npieces left when games ended:")
+	names := make([]string, 0, len(deaths))
+	for name := range deaths {
+		names = append(names, name)
+	}
+	slices.SortFunc(names, func(a, b string) int {
+		if deaths[a] != deaths[b] {
+			return deaths[b] - deaths[a]
+		}
+		return strings.Compare(a, b)
+	})
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-16s %6d  %5.1f%%\n", name, deaths[name], 100*float64(deaths[name])/games)
+	}
+}
+
+// percentile returns the score below which p percent of the sorted scores fall.
+func percentile(sorted []int64, p int) int64 {
+	return sorted[(len(sorted)-1)*p/100]
+}
+
+// writeHistogram draws the sorted scores in equal-width buckets.
+func writeHistogram(w io.Writer, sorted []int64) {
+	low, high := sorted[0], sorted[len(sorted)-1]
+	bucketSize := max((high-low+histogramBuckets)/histogramBuckets, 1)
+	counts := make([]int, histogramBuckets)
+	for _, score := range sorted {
+		counts[min(int((score-low)/bucketSize), histogramBuckets-1)]++
+	}
+	largest := slices.Max(counts)
+	for i, count := range counts {
+		from := low + int64(i)*bucketSize
+		bar := strings.Repeat("#", (count*histogramWidth+largest-1)/largest)
+		fmt.Fprintf(w, "  %7d-%-7d %6d %s\n", from, from+bucketSize-1, count, bar)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"github.com/mikecoop83/blocks/lib"
+)
+
+// strategy chooses the moves a bot plays.  It's called whenever the game isn't over and returns one or more moves to
+// play in order.
+type strategy func(s *lib.Session) []lib.Move
+
+// lookaheadEvaluator keeps every piece placed, then keeps the board as empty as possible.  It leaves out mobility,
+// which makes lib.SurvivalEvaluator too slow for thousands of games.
+var lookaheadEvaluator = lib.WeightedEvaluator(lib.Weights{Placed: 1e6, EmptyCells: 10, Points: 1})
+
+var strategyNames = []string{"random", "greedy", "lookahead", "survival"}
+
+// newStrategy returns the named strategy for the game with the seed.  Strategies that make random choices draw them
+// from the seed, so games are reproducible.
+func newStrategy(name string, seed uint64) (strategy, error) {
+	switch name {
+	case "random":
+		random := rand.New(rand.NewSource(int64(seed)))
+		return func(s *lib.Session) []lib.Move {
+			moves := s.LegalMoves()
+			return []lib.Move{moves[random.Intn(len(moves))].Move}
+		}, nil
+	case "greedy":
+		return greedy, nil
+	case "lookahead":
+		return solver(lookaheadEvaluator), nil
+	case "survival":
+		return solver(lib.SurvivalEvaluator), nil
+	default:
+		return nil, fmt.Errorf("unknown strategy %q, want one of %s", name, strings.Join(strategyNames, ", "))
+	}
+}
+
+// greedy plays the move that scores the most points right now, preferring earlier slots and locations on ties.
+func greedy(s *lib.Session) []lib.Move {
+	var best lib.LegalMove
+	for i, move := range s.LegalMoves() {
+		if i == 0 || move.Placement.Points > best.Placement.Points {
+			best = move
+		}
+	}
+	return []lib.Move{best.Move}
+}
+
+// solver plays the best plan for the whole tray as rated by evaluate.
+func solver(evaluate lib.Evaluator) strategy {
+	return func(s *lib.Session) []lib.Move {
+		plan, _ := s.Solve(evaluate)
+		moves := make([]lib.Move, len(plan.Slots))
+		for i, slot := range plan.Slots {
+			moves[i] = lib.Move{Slot: slot, Loc: plan.Placements[i].Loc, Rotation: plan.Rotations[i]}
+		}
+		return moves
+	}
+}
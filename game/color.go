@@ -0,0 +1,64 @@
+package game
+
+import (
+	"image/color"
+	"time"
+
+	"github.com/mikecoop83/blocks/lib"
+)
+
+// pieceColors are the colors of pieces and the blocks they place, indexed by lib.Color less one.  They avoid the
+// colors of the cell states drawn over the board, such as the green of a pending placement.
+var pieceColors = [lib.NumPieceColors]color.Color{
+	blue,
+	color.RGBA{R: 0x33, G: 0xbb, B: 0xbb, A: 0xff}, // teal
+	color.RGBA{R: 0x99, G: 0x66, B: 0xcc, A: 0xff}, // purple
+	color.RGBA{R: 0xff, G: 0x77, B: 0xaa, A: 0xff}, // pink
+	color.RGBA{R: 0xdd, G: 0xbb, B: 0x33, A: 0xff}, // gold
+	color.RGBA{R: 0x55, G: 0xcc, B: 0xee, A: 0xff}, // sky
+	color.RGBA{R: 0xaa, G: 0x77, B: 0x44, A: 0xff}, // brown
+	color.RGBA{R: 0x55, G: 0x66, B: 0xaa, A: 0xff}, // indigo
+}
+
+// blockColor returns the color to draw a block of the color in.  Blocks without one, such as those a level starts
+// with, are drawn in the color of occupied cells.
+func (g *Game) blockColor(c lib.Color) color.Color {
+	if c == lib.NoColor || int(c) > len(pieceColors) {
+		return displayModeToCellColor[g.displayMode][cellOccupied]
+	}
+	return pieceColors[c-1]
+}
+
+// animateClears fades each block of the lines and boxes the placement cleared from its color to empty.  colors are the
+// cell colors from before the placement.
+func (g *Game) animateClears(placement lib.Placement, colors [][]lib.Color) {
+	piece, loc := placement.Piece, placement.Loc
+	emptyColor := displayModeToCellColor[g.displayMode][cellEmpty]
+	animate := func(r, c int) {
+		blockColor := colors[r][c]
+		if r >= loc.R && r < loc.R+piece.Height() && c >= loc.C && c < loc.C+piece.Width() &&
+			piece.Shape[r-loc.R][c-loc.C] {
+			blockColor = piece.Color
+		}
+		g.clearedCells[r][c] = &animatedEntity{
+			currentColor:  g.blockColor(blockColor),
+			targetColor:   emptyColor,
+			animationTime: 1 * time.Second,
+		}
+	}
+	for _, r := range placement.ClearedRows {
+		for c := range g.boardSize.Width {
+			animate(r, c)
+		}
+	}
+	for _, c := range placement.ClearedCols {
+		for r := range g.boardSize.Height {
+			animate(r, c)
+		}
+	}
+	for _, box := range placement.ClearedBoxes {
+		for _, cell := range g.session.Board().BoxCells(box) {
+			animate(cell.R, cell.C)
+		}
+	}
+}
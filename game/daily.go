@@ -0,0 +1,87 @@
+package game
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/mikecoop83/blocks/lib"
+	"github.com/mikecoop83/blocks/persist"
+)
+
+// dailyConfig is the rules of every daily challenge, whatever the stored settings, so everyone plays the same game.
+func dailyConfig(version lib.RulesVersion) lib.Config {
+	config := lib.DefaultConfig
+	config.Version = version
+	return config
+}
+
+// startDaily starts the daily challenge on the date.  Only the first attempt each day is scored, so once the day has
+// a result any other attempt is practice.
+func (g *Game) startDaily(date string, version lib.RulesVersion) {
+	gameID, err := lib.DailyGameID(date)
+	if err != nil {
+		slog.Error("error starting daily challenge", "error", err)
+		return
+	}
+	session, err := lib.NewSession(gameID, dailyConfig(version))
+	if err != nil {
+		slog.Error("error starting daily challenge", "error", err)
+		return
+	}
+	g.start(session, date)
+	if g.practice {
+		g.flash("Already played")
+	}
+}
+
+// startToday starts today's daily challenge.
+func (g *Game) startToday() {
+	g.startDaily(lib.DailyDate(time.Now()), lib.CurrentRulesVersion)
+}
+
+// dailyKey is the key the result of the daily challenge on the date is stored under.
+func dailyKey(date string) persist.Key[int64] {
+	return persist.Key[int64](dailyKeyPrefix + date)
+}
+
+// loadDailyBest returns the best score of the scored attempt at the daily challenge on the date, and false if it
+// hasn't been played.
+func loadDailyBest(date string) (int64, bool) {
+	best, err := dailyKey(date).Load()
+	if err != nil {
+		if !errors.Is(err, persist.ErrNotFound) {
+			slog.Error("failed to load daily best", "error", err)
+		}
+		return 0, false
+	}
+	return best, true
+}
+
+// updateDailyBest records the score of the scored attempt at a daily challenge.  The attempt is used up by its first
+// move, and points scored after cheating or a hint don't count.
+func (g *Game) updateDailyBest() {
+	if g.daily == "" || g.practice || len(g.session.Moves()) == 0 {
+		return
+	}
+	score := g.session.Score()
+	if g.cheated || g.assisted {
+		score = 0
+	}
+	if !g.dailyPlayed || score > g.dailyBest {
+		g.dailyBest = score
+		g.dailyPlayed = true
+		store(dailyKey(g.daily), score)
+	}
+}
+
+// dailyLabel describes the daily challenge being played for the header, or is "" for other games.
+func (g *Game) dailyLabel() string {
+	if g.daily == "" {
+		return ""
+	}
+	if g.practice {
+		return "Practice"
+	}
+	return "Daily"
+}
@@ -0,0 +1,152 @@
+package game
+
+import (
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"slices"
+	"strings"
+
+	"github.com/mikecoop83/blocks/persist"
+)
+
+const (
+	dailyKeyPrefix = "daily-"
+	levelKeyPrefix = "level-"
+)
+
+// isGameKey reports whether the key is one the game stores data under, as opposed to one of another page on the same
+// site.
+func isGameKey(key string) bool {
+	return slices.Contains([]string{
+		string(persist.SchemaKey), string(boardSizeKey), string(displayModeKey), string(undoPolicyKey),
+		string(scoringKey), string(pieceSetKey), string(distributionKey), string(rotationKey), string(colorBonusKey),
+		string(boxesKey), string(savedGameKey), string(leaderboardKey), string(statsKey),
+	}, key) || strings.HasPrefix(key, dailyKeyPrefix) || strings.HasPrefix(key, levelKeyPrefix)
+}
+
+// exportSaveData hands all of the game's stored data to the player as one string, to be imported on another device.
+func (g *Game) exportSaveData() {
+	text, err := persist.Export(isGameKey)
+	if err == nil {
+		err = writeExport(text)
+	}
+	if err != nil {
+		slog.Error("failed to export save data", "error", err)
+		g.flash("Export failed")
+		return
+	}
+	g.flash(exportedMessage)
+}
+
+// importSaveData merges save data exported on another device into the stored data and starts a new game with it.
+func (g *Game) importSaveData() {
+	text, err := readImport()
+	if err != nil {
+		slog.Error("failed to read save data", "error", err)
+		g.flash("Import failed")
+		return
+	}
+	if text == "" {
+		return
+	}
+	err = importSaveData(text)
+	if err != nil {
+		slog.Error("failed to import save data", "error", err)
+		g.flash("Import failed")
+		return
+	}
+	// The saved game offered before may have been replaced by the imported one, so it's loaded again rather than
+	// dropped.
+	g.savedGame = nil
+	g.Reset(rand.Uint64())
+	g.flash("Imported")
+	g.savedGame = loadSavedGame()
+	if g.savedGame != nil {
+		g.menuOpen = true
+	}
+}
+
+// importSaveData checks the exported text, brings it up to the current schema version and merges it into the stored
+// data.
+func importSaveData(text string) error {
+	imported, err := persist.DecodeExport(text)
+	if err != nil {
+		return err
+	}
+	version, err := persist.SchemaKey.LoadFrom(imported)
+	if err != nil {
+		return err
+	}
+	if latest := migrations[len(migrations)-1].Version; version > latest {
+		return fmt.Errorf("save data has schema version %d, newer than %d", version, latest)
+	}
+	previous := persist.SetBackend(imported)
+	err = persist.Migrate(migrations)
+	persist.SetBackend(previous)
+	if err != nil {
+		return err
+	}
+	keys, err := imported.Keys()
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if !isGameKey(key) || key == string(persist.SchemaKey) {
+			continue
+		}
+		err := mergeImported(imported, key)
+		if err != nil {
+			return fmt.Errorf("merging %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// mergeImported merges the value of the key from imported save data into the stored one.  Scores and progress keep the
+// best of both, the stored game in progress is kept over the imported one, and imported settings replace stored ones.
+func mergeImported(imported persist.Backend, key string) error {
+	switch {
+	case key == string(leaderboardKey):
+		entries, err := leaderboardKey.LoadFrom(imported)
+		if err != nil {
+			return err
+		}
+		merged := load(leaderboardKey)
+		for _, entry := range entries {
+			merged = addScoreEntry(merged, entry)
+		}
+		return leaderboardKey.Store(merged)
+	case key == string(statsKey):
+		// Stats can't be told apart by game, so the set covering more games is kept rather than counting any twice.
+		stats, err := statsKey.LoadFrom(imported)
+		if err != nil || stats.GamesPlayed <= load(statsKey).GamesPlayed {
+			return err
+		}
+		return statsKey.Store(stats)
+	case key == string(savedGameKey):
+		if loadSavedGame() != nil {
+			return nil
+		}
+	case strings.HasPrefix(key, dailyKeyPrefix):
+		best, err := persist.Key[int64](key).LoadFrom(imported)
+		if err != nil {
+			return err
+		}
+		if storedBest, played := loadDailyBest(strings.TrimPrefix(key, dailyKeyPrefix)); played && storedBest >= best {
+			return nil
+		}
+		return persist.Key[int64](key).Store(best)
+	case strings.HasPrefix(key, levelKeyPrefix):
+		solved, err := persist.Key[bool](key).LoadFrom(imported)
+		if err != nil || !solved {
+			return err
+		}
+		return persist.Key[bool](key).Store(solved)
+	}
+	value, err := imported.Load(key)
+	if err != nil {
+		return err
+	}
+	return persist.Store(key, value)
+}
@@ -1,9 +1,11 @@
 package game
 
 import (
+	"fmt"
 	"image/color"
 	"log/slog"
 	"math/rand"
+	"slices"
 	"time"
 
 	"github.com/mikecoop83/blocks/persist"
@@ -30,30 +32,48 @@ var (
 	darkGray    = color.RGBA{R: 0x40, G: 0x40, B: 0x40, A: 0xff}
 	paleYellow  = color.RGBA{R: 0xff, G: 0xff, B: 0xcc, A: 0xff}
 	reddishGray = color.RGBA{R: 0x99, G: 0x66, B: 0x66, A: 0xff}
+	lavender    = color.RGBA{R: 0xb3, G: 0x88, B: 0xff, A: 0xff}
 )
 
 const (
 	splashDuration   = time.Second
-	cellSize         = 100
+	defaultCellSize  = 100
 	topAreaHeight    = 100
-	numPieceOptions  = 3
-	boardWidth       = lib.BoardSize * cellSize
-	boardHeight      = lib.BoardSize * cellSize
-	bottomAreaHeight = lib.BoardSize * cellSize * 0.5
+	numPieceOptions  = lib.TraySize
+	boardWidth       = lib.DefaultBoardSize * defaultCellSize
+	boardHeight      = lib.DefaultBoardSize * defaultCellSize
+	bottomAreaHeight = lib.DefaultBoardSize * defaultCellSize * 0.5
 	WindowWidth      = boardWidth
 	WindowHeight     = topAreaHeight + boardHeight + bottomAreaHeight
 
 	// Menu constants
 	menuButtonSize = topAreaHeight * 0.4
-	menuItemHeight = 100
+	menuItemHeight = 80
 	menuWidth      = 350
 	menuPadding    = 35
 
 	// Flash message constants
 	flashDuration = 500 * time.Millisecond
+
+	scoreBreakdownDuration = 1500 * time.Millisecond
 )
 
-var displayModeToCellColor = map[DisplayMode]map[lib.CellState]color.Color{
+// cellState is how a board cell or tray piece is rendered.  Besides the board's occupancy it covers transient states
+// such as the preview of a dragged piece.
+type cellState int
+
+const (
+	cellEmpty cellState = iota
+	cellPending
+	cellInvalid
+	cellFullLine
+	cellOccupied
+	cellHovering
+	cellCantMove
+	cellHint
+)
+
+var displayModeToCellColor = map[DisplayMode]map[cellState]color.Color{
 	DisplayModeNormal: cellStateToColor,
 	DisplayModeDark:   darkCellStateToColor,
 }
@@ -68,26 +88,26 @@ var displayModeToForegroundColor = map[DisplayMode]color.Color{
 	DisplayModeDark:   offWhite,
 }
 
-var cellStateToColor = map[lib.CellState]color.Color{
-	lib.Empty:    offWhite,
-	lib.Pending:  green,
-	lib.Invalid:  red,
-	lib.FullLine: orange,
-	lib.Occupied: blue,
-	lib.Unchosen: gray,
-	lib.Hovering: paleYellow,
-	lib.CantMove: red,
+var cellStateToColor = map[cellState]color.Color{
+	cellEmpty:    offWhite,
+	cellPending:  green,
+	cellInvalid:  red,
+	cellFullLine: orange,
+	cellOccupied: gray,
+	cellHovering: paleYellow,
+	cellCantMove: red,
+	cellHint:     lavender,
 }
 
-var darkCellStateToColor = map[lib.CellState]color.Color{
-	lib.Empty:    darkGray,
-	lib.Pending:  green,
-	lib.Invalid:  red,
-	lib.FullLine: orange,
-	lib.Occupied: blue,
-	lib.Unchosen: gray,
-	lib.Hovering: paleYellow,
-	lib.CantMove: red,
+var darkCellStateToColor = map[cellState]color.Color{
+	cellEmpty:    darkGray,
+	cellPending:  green,
+	cellInvalid:  red,
+	cellFullLine: orange,
+	cellOccupied: gray,
+	cellHovering: paleYellow,
+	cellCantMove: red,
+	cellHint:     lavender,
 }
 var commaFormatter = message.NewPrinter(language.English)
 
@@ -108,25 +128,73 @@ var nameToDisplayMode = map[string]DisplayMode{
 	"dark":   DisplayModeDark,
 }
 
+type BoardSize struct {
+	Width, Height int
+}
+
+func (s BoardSize) String() string {
+	return fmt.Sprintf("%dx%d", s.Width, s.Height)
+}
+
+var boardSizes = []BoardSize{
+	{Width: lib.DefaultBoardSize, Height: lib.DefaultBoardSize},
+	{Width: 6, Height: 6},
+	{Width: 10, Height: 10},
+	{Width: 9, Height: 12},
+	{Width: 9, Height: 9},
+}
+
+// boxesBoardSize is the board games with boxes are played on when the stored size can't be split into boxes.
+var boxesBoardSize = BoardSize{Width: 9, Height: 9}
+
+// fitsBoxes reports whether the board can be split into boxes.
+func (s BoardSize) fitsBoxes() bool {
+	return s.Width%lib.BoxSize == 0 && s.Height%lib.BoxSize == 0
+}
+
+func parseBoardSize(text string) (BoardSize, bool) {
+	for _, size := range boardSizes {
+		if size.String() == text {
+			return size, true
+		}
+	}
+	return BoardSize{}, false
+}
+
+func loadBoardSize() BoardSize {
+	boardSize, ok := parseBoardSize(load(boardSizeKey))
+	if !ok {
+		return boardSizes[0]
+	}
+	return boardSize
+}
+
 // Game struct represents the game state.
 type Game struct {
-	board *lib.Board
+	session   *lib.Session
+	boardSize BoardSize
+
+	// Board geometry in screen pixels, scaled so the board fits the board area.
+	cellSize       int
+	boardX, boardY int
 
-	gameID       uint64
-	randSource   rand.Source
-	updateGameID func(gameID uint64)
+	updateLink func(link Link)
 
-	pieceOptions       [numPieceOptions]*lib.Piece
 	pieceOptionCanMove [numPieceOptions]bool
 
-	clearedRows [lib.BoardSize]*animatedEntity
-	clearedCols [lib.BoardSize]*animatedEntity
+	// clearedCells animate the blocks of cleared lines fading out, by row and column.
+	clearedCells [][]*animatedEntity
 
 	touchEnabled       bool
 	pressX, pressY     int
 	dragX, dragY       int
 	releaseX, releaseY int
 	chosenPieceIdx     int
+	// dragTouchID is the touch that drives dragging.  Other touches at the same time only rotate the piece.
+	dragTouchID ebiten.TouchID
+
+	// rotations are the quarter turns the player has given each tray piece in games with player rotation.
+	rotations [numPieceOptions]int
 
 	score       int64
 	highScore   int64
@@ -135,63 +203,206 @@ type Game struct {
 	cheated     bool
 	displayMode DisplayMode
 
+	// Hint state.  Using a hint marks the game as assisted, which like cheated keeps it from setting a high score.
+	hint     *hint
+	assisted bool
+
+	// daily is the date of the daily challenge being played, or "" for other games.  practice is set if the day's
+	// scored attempt was already used, and dailyBest is the best score of that attempt if dailyPlayed is set.
+	daily       string
+	practice    bool
+	dailyBest   int64
+	dailyPlayed bool
+
+	undoPolicy UndoPolicy
+	// highScoreUsedUndo is set if the game that set the high score took back moves.
+	highScoreUsedUndo bool
+
+	// started is when the game was first started, in Unix nanoseconds, which identifies its leaderboard entry.
+	// recorded is the entry last recorded there.
+	started  int64
+	recorded scoreEntry
+	// leaderboardOpen shows the leaderboard instead of the game, with the entries loaded when it was opened.
+	leaderboardOpen bool
+	leaderboard     []scoreEntry
+
+	// playStart is when play of the game started, less any time it was played before being saved, and counted is what
+	// has been added to the stats for the game, or nil.  statsOpen shows the stats loaded when it was opened.
+	playStart time.Time
+	counted   *countedGame
+	statsOpen bool
+	stats     lifetimeStats
+
 	splashStart time.Time
 
-	// Menu state
-	menuOpen bool
+	// Menu state.  settingsOpen shows the settings instead of the main menu.
+	menuOpen     bool
+	settingsOpen bool
+
+	// savedGame is an unfinished game from a previous run that can be continued.
+	savedGame *savedGame
 
 	// Flash message state
 	flashMessage     string
 	flashMessageTime time.Time
+
+	// Breakdown of the points for the last placement
+	scoreBreakdown     lib.ScoreBreakdown
+	scoreBreakdownTime time.Time
 }
 
+// Reset starts the game with the ID using the stored settings.
 func (g *Game) Reset(gameID uint64) {
-	newBoard := lib.NewBoard()
-	g.board = &newBoard
-	g.pieceOptions = [numPieceOptions]*lib.Piece{}
+	g.startGame(gameID, loadConfig())
+}
+
+// open starts the game a link points to.  The link decides the choices it carries and the stored settings the rest.
+func (g *Game) open(link Link) {
+	if link.Daily != "" {
+		g.startDaily(link.Daily, link.Version)
+		return
+	}
+	if link.Level != "" {
+		level, err := lib.LookupLevel(link.Level)
+		if err != nil {
+			slog.Error("error opening level", "error", err)
+			g.Reset(rand.Uint64())
+			return
+		}
+		g.startGame(0, level.Config())
+		return
+	}
+	g.startGame(link.GameID, link.config())
+}
+
+// retry starts the current game over with the same rules.
+func (g *Game) retry() {
+	if g.daily != "" {
+		g.startDaily(g.daily, g.session.Config().Version)
+		return
+	}
+	g.startGame(g.session.GameID(), g.session.Config())
+}
+
+func (g *Game) startGame(gameID uint64, config lib.Config) {
+	session, err := lib.NewSession(gameID, config)
+	if err != nil {
+		slog.Error("error starting game", "error", err)
+		session, _ = lib.NewSession(gameID, lib.DefaultConfig)
+	}
+	g.start(session, "")
+}
+
+// start switches to playing the session, resetting all other per-game state.  daily is the date of the daily
+// challenge the session plays, or "".
+func (g *Game) start(session *lib.Session, daily string) {
+	// A game that's over was counted when it ended, and time spent looking at it since isn't play.  One that isn't can
+	// still be continued after a restart, so it's saved again along with what's now counted for it.
+	if g.session != nil && !g.gameOver && len(g.session.Moves()) > 0 {
+		g.recordGame()
+		g.saveGame()
+	}
+	g.dropSavedGame()
+	g.session = session
+	g.playStart, g.counted = time.Now(), nil
+	g.daily = daily
+	g.dailyBest, g.dailyPlayed = 0, false
+	if daily != "" {
+		g.dailyBest, g.dailyPlayed = loadDailyBest(daily)
+	}
+	g.practice = g.dailyPlayed
+	g.boardSize = BoardSize{Width: session.Board().Width(), Height: session.Board().Height()}
+	g.cellSize = min(boardWidth/g.boardSize.Width, boardHeight/g.boardSize.Height)
+	g.boardX = (boardWidth - g.boardSize.Width*g.cellSize) / 2
+	g.boardY = topAreaHeight + (boardHeight-g.boardSize.Height*g.cellSize)/2
+	g.clearedCells = make([][]*animatedEntity, g.boardSize.Height)
+	for r := range g.clearedCells {
+		g.clearedCells[r] = make([]*animatedEntity, g.boardSize.Width)
+	}
 	g.chosenPieceIdx = -1
-	g.score = 0
+	g.rotations = [numPieceOptions]int{}
 	g.gameOver = false
-	g.highScore = maybeGetHighScore()
+	g.loadHighScore()
+	g.started, g.recorded = time.Now().UnixNano(), scoreEntry{}
+	g.undoPolicy = loadUndoPolicy()
 	g.cheated = false
+	g.assisted = false
+	g.hint = nil
 	g.menuOpen = false
 	g.flashMessage = ""
-	displayModeText, err := persist.Load("displaymode")
-	if err != nil {
-		slog.Error("error loading display mode: %v", err)
-	}
-	g.displayMode = nameToDisplayMode[displayModeText]
-	g.gameID = gameID
-	g.randSource = rand.NewSource(int64(g.gameID))
-	g.updateGameID(g.gameID)
+	g.displayMode = nameToDisplayMode[load(displayModeKey)]
+	g.updateLink(g.link())
 }
 
-func New(gameID uint64, updateGameID func(gameID uint64)) ebiten.Game {
+// New starts the game the link points to, or a new game with the stored settings if the link has no game ID or level.
+func New(link Link, updateLink func(link Link)) ebiten.Game {
+	err := persist.Migrate(migrations)
+	if err != nil {
+		slog.Error("failed to migrate stored data", "error", err)
+	}
 	game := &Game{
-		updateGameID: updateGameID,
+		updateLink: updateLink,
+	}
+	if link.GameID == 0 && link.Level == "" {
+		game.Reset(rand.Uint64())
+	} else {
+		game.open(link)
+	}
+	// Offer to continue an unfinished game from a previous run.
+	game.savedGame = loadSavedGame()
+	if game.savedGame != nil {
+		game.menuOpen = true
 	}
-	game.Reset(gameID)
 	return game
 }
 
+// resume continues a saved game.
+func (g *Game) resume(saved *savedGame) {
+	session, err := lib.Replay(saved.Record)
+	if err != nil {
+		slog.Error("failed to replay saved game", "error", err)
+		return
+	}
+	g.start(session, saved.Daily)
+	g.cheated = saved.Cheated
+	g.assisted = saved.Assisted
+	g.practice = saved.Practice
+	if saved.Started != 0 {
+		g.started = saved.Started
+		// Carry on from the entry already recorded, so its best score isn't replaced by a lower one after an undo.
+		for _, entry := range load(leaderboardKey) {
+			if entry.Started == g.started {
+				g.recorded = entry
+			}
+		}
+	}
+	g.playStart = g.playStart.Add(-saved.Played)
+	g.counted = saved.Counted
+}
+
+// chosenMove is the move that places the chosen piece at loc.
+func (g *Game) chosenMove(loc lib.Location) lib.Move {
+	if g.cheating {
+		return lib.Move{Slot: lib.CheatSlot, Loc: loc}
+	}
+	return lib.Move{Slot: g.chosenPieceIdx, Loc: loc, Rotation: g.rotations[g.chosenPieceIdx]}
+}
+
 func (g *Game) chosenPiece() *lib.Piece {
 	if g.cheating {
-		return &lib.AllPieces[0]
+		return &lib.CheatPiece
 	}
-	if g.chosenPieceIdx < 0 || g.chosenPieceIdx >= len(g.pieceOptions) {
+	if g.chosenPieceIdx < 0 || g.chosenPieceIdx >= numPieceOptions {
 		return nil
 	}
-	return g.pieceOptions[g.chosenPieceIdx]
+	return g.trayPiece(g.chosenPieceIdx)
 }
 
 // Update is called every tick (1/60 seconds by default) to tick the game state.
 func (g *Game) Update() error {
 	switchMode := func() {
 		g.displayMode = (g.displayMode + 1) % 2
-		err := persist.Store("displaymode", displayModeToName[g.displayMode])
-		if err != nil {
-			slog.Error("error storing display mode: %v", err)
-		}
+		store(displayModeKey, displayModeToName[g.displayMode])
 	}
 	if inpututil.IsKeyJustReleased(ebiten.KeyM) {
 		switchMode()
@@ -200,10 +411,8 @@ func (g *Game) Update() error {
 		g.splashStart = time.Now()
 	}
 
-	if !g.cheated && g.score > g.highScore {
-		g.highScore = g.score
-		maybeUpdateHighScore(g.highScore)
-	}
+	g.updateLeaderboard()
+	g.updateDailyBest()
 	g.cheating = ebiten.IsKeyPressed(ebiten.KeyMeta) && ebiten.IsKeyPressed(ebiten.KeyShift)
 	var pressedTouchIDs, dragTouchIDs, releasedTouchIDs []ebiten.TouchID
 	pressedTouchIDs = inpututil.AppendJustPressedTouchIDs(pressedTouchIDs)
@@ -218,20 +427,26 @@ func (g *Game) Update() error {
 			switchMode()
 		}
 		for _, id := range pressedTouchIDs {
-			g.pressX, g.pressY = ebiten.TouchPosition(id)
+			if len(dragTouchIDs) == 1 {
+				g.dragTouchID = id
+				g.pressX, g.pressY = ebiten.TouchPosition(id)
+			}
 		}
 		for _, id := range dragTouchIDs {
+			if id != g.dragTouchID {
+				continue
+			}
 			dragX, dragY := ebiten.TouchPosition(id)
 			// Offset touch dragY to be above your finger by a bit more than the height of the piece to see where you're
 			// dragging it
 			var dragYOffset int
 			chosenPiece := g.chosenPiece()
 			if chosenPiece != nil {
-				dragYOffset = (chosenPiece.Height() + 1) * cellSize
+				dragYOffset = (chosenPiece.Height() + 1) * g.cellSize
 			}
 			g.dragX, g.dragY = dragX, dragY-dragYOffset
 		}
-		if len(releasedTouchIDs) > 0 {
+		if slices.Contains(releasedTouchIDs, g.dragTouchID) {
 			g.releaseX, g.releaseY = g.dragX, g.dragY
 			g.dragX, g.dragY = -1, -1
 			g.pressX, g.pressY = -1, -1
@@ -252,53 +467,42 @@ func (g *Game) Update() error {
 			g.pressX, g.pressY = -1, -1
 		}
 	}
+	g.updateRotation(pressedTouchIDs, dragTouchIDs)
 	if inpututil.IsKeyJustReleased(ebiten.KeyR) {
-		g.Reset(rand.Uint64())
+		g.newGame()
 	}
-
-	// If no pieces to choose, get numPieceOptions new pieces and set first piece to be chosen.
-	if g.pieceOptions[0] == nil && g.pieceOptions[1] == nil && g.pieceOptions[2] == nil {
-		for i := 0; i < numPieceOptions; i++ {
-			randomPiece := lib.RandomRotatedPiece(g.randSource)
-			g.pieceOptions[i] = &randomPiece
+	if inpututil.IsKeyJustReleased(ebiten.KeyH) {
+		g.showHint()
+	}
+	if inpututil.IsKeyJustReleased(ebiten.KeyZ) &&
+		(ebiten.IsKeyPressed(ebiten.KeyControl) || ebiten.IsKeyPressed(ebiten.KeyMeta)) {
+		if ebiten.IsKeyPressed(ebiten.KeyShift) {
+			g.redo()
+		} else {
+			g.undo()
 		}
 	}
+
 	// Check if the game is over.
-	var hasValidMove [numPieceOptions]bool
-	var hasAnyValidMove bool
-	for p, piece := range g.pieceOptions {
-		g.pieceOptionCanMove[p] = false
-		if piece == nil {
-			continue
-		}
-	outer:
-		for r := range lib.BoardSize {
-			for c := range lib.BoardSize {
-				pieceLoc := lib.PieceLocation{
-					Piece: *piece,
-					Loc:   lib.Location{C: c, R: r},
-				}
-				if g.board.ValidatePiece(pieceLoc, false) {
-					hasValidMove[p] = true
-					hasAnyValidMove = true
-					break outer
-				}
-			}
-		}
+	for p := range g.pieceOptionCanMove {
+		g.pieceOptionCanMove[p] = g.session.CanMove(p)
 	}
-	g.pieceOptionCanMove = hasValidMove
-	if !hasAnyValidMove {
+	if !g.gameOver && g.session.GameOver() {
 		g.gameOver = true
+		slog.Info("game over", "record", g.session.Record())
+		clearSavedGame()
+		g.recordSolvedLevel()
+		g.recordGame()
 	}
 
 	// Update the animations for cleared rows and columns.
-	for _, rowsAndColumns := range [2]*[lib.BoardSize]*animatedEntity{&g.clearedRows, &g.clearedCols} {
-		for i, entity := range rowsAndColumns {
+	for _, row := range g.clearedCells {
+		for c, entity := range row {
 			if entity == nil {
 				continue
 			}
 			if entity.tick() {
-				rowsAndColumns[i] = nil
+				row[c] = nil
 			}
 		}
 	}
@@ -314,6 +518,15 @@ func (g *Game) Draw(screen *ebiten.Image) {
 		return
 	}
 
+	if g.leaderboardOpen {
+		g.drawLeaderboard(screen)
+		return
+	}
+	if g.statsOpen {
+		g.drawStats(screen)
+		return
+	}
+
 	g.drawGame(screen)
 }
 
@@ -329,27 +542,35 @@ func (g *Game) drawGame(screen *ebiten.Image) {
 
 	g.drawOverlay(screen)
 
+	g.drawScoreBreakdown(screen)
+
 	g.drawPieceOptions(screen)
 
+	g.drawPiecesLeft(screen)
+
 	g.drawHeader(screen)
 }
 
 func (g *Game) drawPieceOptions(screen *ebiten.Image) {
 	// Draw the bottom area with the piece options
 	const bottomAreaOffset = topAreaHeight + boardHeight
-	const pieceOptionCellSize = cellSize * 0.5
+	const pieceOptionCellSize = defaultCellSize * 0.5
 	pieceOptionWidth := boardWidth / numPieceOptions
 	stateToColor := displayModeToCellColor[g.displayMode]
-	for p, piece := range g.pieceOptions {
+	for p := range g.session.Tray() {
+		piece := g.trayPiece(p)
 		if piece == nil {
 			continue
 		}
-		pieceOptionColor := stateToColor[lib.Unchosen]
+		pieceOptionColor := g.blockColor(piece.Color)
+		if g.hint != nil && g.hint.slot == p {
+			pieceOptionColor = stateToColor[cellHint]
+		}
 		if p == g.chosenPieceIdx && g.releaseX >= 0 && g.releaseY >= 0 {
-			pieceOptionColor = stateToColor[lib.Pending]
+			pieceOptionColor = stateToColor[cellPending]
 		}
 		if !g.pieceOptionCanMove[p] {
-			pieceOptionColor = stateToColor[lib.CantMove]
+			pieceOptionColor = stateToColor[cellCantMove]
 		}
 		yOffset := (bottomAreaHeight - piece.Height()*pieceOptionCellSize) / 2
 		xOffset := (pieceOptionWidth - piece.Width()*pieceOptionCellSize) / 2
@@ -362,7 +583,7 @@ func (g *Game) drawPieceOptions(screen *ebiten.Image) {
 		pieceAreaY := bottomAreaOffset
 		if g.pressX >= pieceAreaX && g.pressX < pieceAreaX+pieceOptionWidth &&
 			g.pressY >= pieceAreaY && g.pressY < pieceAreaY+bottomAreaHeight {
-			pieceOptionColor = stateToColor[lib.Hovering]
+			pieceOptionColor = stateToColor[cellHovering]
 			g.chosenPieceIdx = p
 		}
 		for r := range piece.Shape {
@@ -396,23 +617,33 @@ func (g *Game) drawPieceOptions(screen *ebiten.Image) {
 }
 
 func (g *Game) drawOverlay(screen *ebiten.Image) {
-	// Draw gridlines
-	for i := 0; i <= lib.BoardSize; i++ {
-		// Horizontal line
+	boardPixelWidth := g.session.Board().Width() * g.cellSize
+	boardPixelHeight := g.session.Board().Height() * g.cellSize
+	// Draw gridlines, with thicker ones around boxes in games that clear them
+	lineWidth := func(i int) float32 {
+		if g.session.Config().Boxes && i%lib.BoxSize == 0 {
+			return 3
+		}
+		return 1
+	}
+	for c := 0; c <= g.session.Board().Width(); c++ {
+		// Vertical line
 		vector.StrokeLine(
 			screen,
-			float32(i*cellSize), float32(topAreaHeight),
-			float32(i*cellSize), float32(topAreaHeight+boardHeight),
-			1,
+			float32(g.boardX+c*g.cellSize), float32(g.boardY),
+			float32(g.boardX+c*g.cellSize), float32(g.boardY+boardPixelHeight),
+			lineWidth(c),
 			displayModeToForegroundColor[g.displayMode],
 			false,
 		)
-		// Vertical line
+	}
+	for r := 0; r <= g.session.Board().Height(); r++ {
+		// Horizontal line
 		vector.StrokeLine(
 			screen,
-			0, float32(topAreaHeight+i*cellSize),
-			boardWidth, float32(topAreaHeight+i*cellSize),
-			1,
+			float32(g.boardX), float32(g.boardY+r*g.cellSize),
+			float32(g.boardX+boardPixelWidth), float32(g.boardY+r*g.cellSize),
+			lineWidth(r),
 			displayModeToForegroundColor[g.displayMode],
 			false,
 		)
@@ -421,32 +652,48 @@ func (g *Game) drawOverlay(screen *ebiten.Image) {
 	if g.gameOver {
 		vector.DrawFilledRect(
 			screen,
-			0, float32(topAreaHeight),
-			boardWidth, boardHeight,
+			float32(g.boardX), float32(g.boardY),
+			float32(boardPixelWidth), float32(boardPixelHeight),
 			color.RGBA{R: 0, G: 0, B: 0, A: 0x80},
 			false,
 		)
 	}
 }
 
+// boardCells maps the board's occupancy to the cell states to draw.
+func (g *Game) boardCells() [][]cellState {
+	grid := g.session.Board().GetGrid()
+	cells := make([][]cellState, len(grid))
+	for r := range grid {
+		cells[r] = make([]cellState, len(grid[r]))
+		for c := range grid[r] {
+			if grid[r][c] == lib.Occupied {
+				cells[r][c] = cellOccupied
+			}
+		}
+	}
+	return cells
+}
+
 func (g *Game) drawBoard(screen *ebiten.Image) {
-	grid := g.board.GetGrid()
-	stateToColor := displayModeToCellColor[g.displayMode]
+	cells := g.boardCells()
+	colors := g.session.Colors()
+	g.markHint(cells)
 
 	// Either drag or click is the current mouse position.
 	mouseX, mouseY := g.dragX, g.dragY
 	if mouseX < 0 {
 		mouseX, mouseY = g.releaseX, g.releaseY
 	}
-	onBoard := mouseX >= 0 &&
-		mouseX < boardWidth &&
-		mouseY >= topAreaHeight &&
-		mouseY < topAreaHeight+boardHeight
+	onBoard := mouseX >= g.boardX &&
+		mouseX < g.boardX+g.session.Board().Width()*g.cellSize &&
+		mouseY >= g.boardY &&
+		mouseY < g.boardY+g.session.Board().Height()*g.cellSize
 
 	if onBoard && g.chosenPiece() != nil {
 		piece := *g.chosenPiece()
-		cellC := mouseX / cellSize
-		cellR := (mouseY - topAreaHeight) / cellSize
+		cellC := (mouseX - g.boardX) / g.cellSize
+		cellR := (mouseY - g.boardY) / g.cellSize
 
 		// Clamp the piece to the board if the mouse is on the board
 		if cellC < 0 {
@@ -455,73 +702,55 @@ func (g *Game) drawBoard(screen *ebiten.Image) {
 		if cellR < 0 {
 			cellR = 0
 		}
-		if cellC > lib.BoardSize-piece.Width() {
-			cellC = lib.BoardSize - piece.Width()
+		if cellC > g.session.Board().Width()-piece.Width() {
+			cellC = g.session.Board().Width() - piece.Width()
 		}
-		if cellR > lib.BoardSize-piece.Height() {
-			cellR = lib.BoardSize - piece.Height()
+		if cellR > g.session.Board().Height()-piece.Height() {
+			cellR = g.session.Board().Height() - piece.Height()
 		}
-		pending := true
-		if g.releaseX >= 0 && g.releaseY >= 0 {
-			pending = false
+		pieceLoc := lib.PieceLocation{
+			Piece: piece,
+			Loc:   lib.Location{C: cellC, R: cellR},
 		}
-		pendingGrid, clearedRows, clearedCols, valid := g.board.AddPiece(
-			lib.PieceLocation{
-				Piece: piece,
-				Loc:   lib.Location{C: cellC, R: cellR},
-			},
-			pending,
-		)
-		if valid || pending {
-			grid = pendingGrid
-		}
-		if valid && !pending {
-			numPoints := g.chosenPiece().NumBlocks()
-			numClearedLines := len(clearedRows) + len(clearedCols)
-			numPoints += numClearedLines * 10
-			if grid.Empty() {
-				numPoints += 300
-			}
-			for _, r := range clearedRows {
-				g.clearedRows[r] = &animatedEntity{
-					currentColor:  stateToColor[lib.FullLine],
-					targetColor:   stateToColor[lib.Empty],
-					animationTime: 1 * time.Second,
-				}
-			}
-			for _, c := range clearedCols {
-				g.clearedCols[c] = &animatedEntity{
-					currentColor:  stateToColor[lib.FullLine],
-					targetColor:   stateToColor[lib.Empty],
-					animationTime: 1 * time.Second,
-				}
+		released := g.releaseX >= 0 && g.releaseY >= 0
+		if !released {
+			if preview, ok := g.session.Board().Preview(pieceLoc); ok {
+				g.markPreview(cells, preview)
 			}
-			g.score += int64(numPoints)
-			if !g.cheating {
-				g.pieceOptions[g.chosenPieceIdx] = nil
-			} else {
+		} else if placement, err := g.session.Play(g.chosenMove(pieceLoc.Loc)); err == nil {
+			cells = g.boardCells()
+			g.animateClears(placement, colors)
+			g.hint = nil
+			g.scoreBreakdown = placement.Breakdown
+			g.scoreBreakdownTime = time.Now()
+			if g.cheating {
 				g.cheated = true
+			} else {
+				// Cheat blocks aren't pieces dealt, so they'd only skew the stats.
+				recordMove(placement)
+				g.rotations[g.chosenPieceIdx] = 0
 			}
+			g.dropSavedGame()
+			g.saveGame()
 		}
 	}
 	// Draw the cells
-	for r := range grid {
-		for c := range grid[r] {
-			state := grid[r][c]
+	colors = g.session.Colors()
+	for r := range cells {
+		for c := range cells[r] {
+			state := cells[r][c]
 			displayColors := displayModeToCellColor[g.displayMode]
 			displayColor := displayColors[state]
-			if state == lib.Empty {
-				if g.clearedCols[c] != nil {
-					displayColor = g.clearedCols[c].currentColor
-				}
-				if g.clearedRows[r] != nil {
-					displayColor = g.clearedRows[r].currentColor
-				}
+			switch {
+			case state == cellOccupied:
+				displayColor = g.blockColor(colors[r][c])
+			case state == cellEmpty && g.clearedCells[r][c] != nil:
+				displayColor = g.clearedCells[r][c].currentColor
 			}
 			vector.DrawFilledRect(
 				screen,
-				float32(c*cellSize), float32(topAreaHeight+r*cellSize),
-				cellSize, cellSize,
+				float32(g.boardX+c*g.cellSize), float32(g.boardY+r*g.cellSize),
+				float32(g.cellSize), float32(g.cellSize),
 				displayColor,
 				false,
 			)
@@ -529,6 +758,226 @@ func (g *Game) drawBoard(screen *ebiten.Image) {
 	}
 }
 
+// markPreview marks the cells a dragged piece would fill, the cells it collides with and, if it fits, the existing
+// cells of the lines it would clear.
+func (g *Game) markPreview(cells [][]cellState, preview lib.Preview) {
+	for _, loc := range preview.Overlapping {
+		cells[loc.R][loc.C] = cellInvalid
+	}
+	for _, loc := range preview.Filled {
+		cells[loc.R][loc.C] = cellPending
+	}
+	if !preview.Valid() {
+		return
+	}
+	for _, r := range preview.ClearedRows {
+		for c := range cells[r] {
+			if cells[r][c] != cellPending {
+				cells[r][c] = cellFullLine
+			}
+		}
+	}
+	for _, c := range preview.ClearedCols {
+		for r := range cells {
+			if cells[r][c] != cellPending {
+				cells[r][c] = cellFullLine
+			}
+		}
+	}
+	for _, box := range preview.ClearedBoxes {
+		for _, loc := range g.session.Board().BoxCells(box) {
+			if cells[loc.R][loc.C] != cellPending {
+				cells[loc.R][loc.C] = cellFullLine
+			}
+		}
+	}
+}
+
+type menuItem struct {
+	label  string
+	action func()
+}
+
+func (g *Game) menuItems() []menuItem {
+	if g.settingsOpen {
+		return g.settingsItems()
+	}
+	var items []menuItem
+	if g.savedGame != nil {
+		items = append(items, menuItem{
+			label: "Continue",
+			action: func() {
+				saved := g.savedGame
+				g.savedGame = nil
+				g.resume(saved)
+			},
+		})
+	}
+	items = append(items, []menuItem{
+		{
+			label: "Copy game link",
+			action: func() {
+				copyToClipboard(getGameURL())
+				g.flash("Copied!")
+			},
+		},
+		{
+			label: "Hint",
+			action: func() {
+				g.showHint()
+			},
+		},
+		{
+			label: "Undo",
+			action: func() {
+				g.undo()
+			},
+		},
+		{
+			label: "Redo",
+			action: func() {
+				g.redo()
+			},
+		},
+		{
+			label: "Retry game",
+			action: func() {
+				g.retry()
+			},
+		},
+		{
+			label: "New game",
+			action: func() {
+				g.newGame()
+			},
+		},
+		{
+			label: "Mode: " + playModeToName[g.playMode()],
+			action: func() {
+				g.switchPlayMode()
+			},
+		},
+	}...)
+	if g.playMode() == modePuzzle {
+		items = append(items, menuItem{
+			label: g.levelMenuLabel(),
+			action: func() {
+				g.startLevel((g.levelIndex() + 1) % len(lib.Levels()))
+			},
+		})
+	}
+	return append(items, menuItem{
+		label: "High scores",
+		action: func() {
+			g.leaderboard = load(leaderboardKey)
+			g.leaderboardOpen = true
+		},
+	}, menuItem{
+		label: "Stats",
+		action: func() {
+			g.stats = load(statsKey)
+			g.statsOpen = true
+		},
+	}, menuItem{
+		label: "Settings",
+		action: func() {
+			g.menuOpen = true
+			g.settingsOpen = true
+		},
+	})
+}
+
+// settingsItems are the menu items that change the rules of endless games.
+func (g *Game) settingsItems() []menuItem {
+	return []menuItem{
+		{
+			label: "Board: " + g.boardSize.String(),
+			action: func() {
+				g.switchBoardSize()
+			},
+		},
+		{
+			label: undoPolicyToLabel[g.undoPolicy],
+			action: func() {
+				g.switchUndoPolicy()
+			},
+		},
+		{
+			label: "Scoring: " + g.session.Config().Scoring.String(),
+			action: func() {
+				g.switchScoringRules()
+			},
+		},
+		{
+			label: "Pieces: " + pieceSetLabel(g.session.Config().Pieces),
+			action: func() {
+				g.switchPieceSet()
+			},
+		},
+		{
+			label: "Dealing: " + g.session.Config().Distribution.String(),
+			action: func() {
+				g.switchPieceDistribution()
+			},
+		},
+		{
+			label: "Rotation: " + rotationLabel(g.session.Config().PlayerRotation),
+			action: func() {
+				g.switchPlayerRotation()
+			},
+		},
+		{
+			label: "Color bonus: " + onOffLabel(g.session.Config().ColorBonus),
+			action: func() {
+				g.switchColorBonus()
+			},
+		},
+		{
+			label: "Boxes: " + onOffLabel(g.session.Config().Boxes),
+			action: func() {
+				g.switchBoxes()
+			},
+		},
+		{
+			label: "Export save data",
+			action: func() {
+				g.exportSaveData()
+			},
+		},
+		{
+			label: "Import save data",
+			action: func() {
+				g.importSaveData()
+			},
+		},
+		{
+			label: "Back",
+			action: func() {
+				g.menuOpen = true
+			},
+		},
+	}
+}
+
+// switchBoardSize cycles to the next board size and starts a new game on it.  Sizes that can't be split into boxes
+// are skipped while boxes are on.
+func (g *Game) switchBoardSize() {
+	next := boardSizes[0]
+	for i, size := range boardSizes {
+		if size == g.boardSize {
+			for j := 1; j < len(boardSizes); j++ {
+				next = boardSizes[(i+j)%len(boardSizes)]
+				if !g.session.Config().Boxes || next.fitsBoxes() {
+					break
+				}
+			}
+			break
+		}
+	}
+	store(boardSizeKey, next.String())
+	g.Reset(rand.Uint64())
+}
+
 func (g *Game) drawHeader(screen *ebiten.Image) {
 	// High score at top left
 	op := &ebiten.DrawImageOptions{}
@@ -541,10 +990,16 @@ func (g *Game) drawHeader(screen *ebiten.Image) {
 
 	screen.DrawImage(resources.FirstPlaceImage, op)
 
+	// Daily challenges show the day's best instead.
 	highScoreMsg := commaFormatter.Sprintf("%d", g.highScore)
+	if g.daily != "" {
+		highScoreMsg = commaFormatter.Sprintf("%d", g.dailyBest)
+	} else if g.highScoreUsedUndo {
+		highScoreMsg += "*"
+	}
 	_, highScoreHeight := getTextSize(highScoreMsg, resources.TextFontFace)
 	highScoreColor := displayModeToForegroundColor[g.displayMode]
-	if g.cheated {
+	if g.cheated || g.assisted {
 		highScoreColor = reddishGray
 	}
 	text.Draw(
@@ -558,7 +1013,7 @@ func (g *Game) drawHeader(screen *ebiten.Image) {
 	)
 
 	// Score at top right
-	scoreMsg := commaFormatter.Sprintf("%d", g.score)
+	scoreMsg := commaFormatter.Sprintf("%d", g.session.Score())
 	scoreWidth, scoreHeight := getTextSize(scoreMsg, resources.TextFontFace)
 	text.Draw(
 		screen,
@@ -583,6 +1038,17 @@ func (g *Game) drawHeader(screen *ebiten.Image) {
 		)
 	} else {
 		g.flashMessage = ""
+		if modeMsg := g.modeLabel(); modeMsg != "" && !g.gameOver {
+			modeWidth, modeHeight := getTextSize(modeMsg, resources.SmallTextFontFace)
+			text.Draw(
+				screen,
+				modeMsg,
+				resources.SmallTextFontFace,
+				int((boardWidth-modeWidth)/2),
+				int(((topAreaHeight-modeHeight)/2)+modeHeight),
+				displayModeToForegroundColor[g.displayMode],
+			)
+		}
 	}
 
 	// Draw menu button (three dots)
@@ -598,6 +1064,7 @@ func (g *Game) drawHeader(screen *ebiten.Image) {
 	if float64(g.releaseX) >= menuX && float64(g.releaseX) <= menuX+menuButtonSize &&
 		float64(g.releaseY) >= menuY && float64(g.releaseY) <= menuY+menuButtonSize {
 		g.menuOpen = !g.menuOpen
+		g.settingsOpen = false
 		g.releaseX, g.releaseY = -1, -1
 	}
 
@@ -616,7 +1083,7 @@ func (g *Game) drawHeader(screen *ebiten.Image) {
 
 	// Draw menu if open
 	if g.menuOpen {
-		menuItems := []string{"Copy game link", "Retry game", "New game"}
+		menuItems := g.menuItems()
 		menuX = float64(boardWidth - int(menuWidth) - 10)
 		menuY = float64(topAreaHeight + 5)
 
@@ -665,7 +1132,7 @@ func (g *Game) drawHeader(screen *ebiten.Image) {
 		// Draw menu items with smaller font
 		for i, item := range menuItems {
 			itemY := menuY + float64(i*menuItemHeight)
-			_, textHeight := getTextSize(item, resources.SmallTextFontFace)
+			_, textHeight := getTextSize(item.label, resources.SmallTextFontFace)
 
 			// Center text vertically in menu item
 			textY := itemY + (float64(menuItemHeight)-float64(textHeight))/2 + float64(textHeight)
@@ -673,7 +1140,7 @@ func (g *Game) drawHeader(screen *ebiten.Image) {
 			// Draw menu item text
 			text.Draw(
 				screen,
-				item,
+				item.label,
 				resources.SmallTextFontFace,
 				int(menuX)+menuPadding,
 				int(textY),
@@ -699,18 +1166,11 @@ func (g *Game) drawHeader(screen *ebiten.Image) {
 		if float64(g.releaseX) >= menuX && float64(g.releaseX) <= menuX+menuWidth {
 			itemIdx := (float64(g.releaseY) - menuY) / float64(menuItemHeight)
 			if itemIdx >= 0 && itemIdx < float64(len(menuItems)) {
-				switch int(itemIdx) {
-				case 0: // Copy game link
-					copyToClipboard(getGameURL())
-					g.flashMessage = "Copied!"
-					g.flashMessageTime = time.Now()
-				case 1: // Retry same game
-					g.Reset(g.gameID)
-				case 2: // New game
-					g.Reset(rand.Uint64())
-				}
+				// Close the menu first so that actions can open it again.
 				g.menuOpen = false
+				g.settingsOpen = false
 				g.releaseX, g.releaseY = -1, -1
+				menuItems[int(itemIdx)].action()
 			}
 		}
 
@@ -724,6 +1184,9 @@ func (g *Game) drawHeader(screen *ebiten.Image) {
 	// Game over in the middle
 	if g.gameOver {
 		gameOverMsg := "Game Over"
+		if g.session.Solved() {
+			gameOverMsg = "Solved!"
+		}
 		gameOverWidth, gameOverHeight := getTextSize(gameOverMsg, resources.TextFontFace)
 		restartImageWidth := iconWidth
 		restartImageHeight := iconHeight
@@ -751,11 +1214,42 @@ func (g *Game) drawHeader(screen *ebiten.Image) {
 		// Clicked on the restart image
 		if g.releaseX >= restartImageX && g.releaseX <= restartImageX+int(restartImageWidth) &&
 			g.releaseY >= int(restartImageY) && g.releaseY <= int(restartImageY+restartImageHeight) {
-			g.Reset(rand.Uint64())
+			g.newGame()
 		}
 	}
 }
 
+// drawScoreBreakdown shows how the points for the last placement were awarded, fading out over the top of the board.
+func (g *Game) drawScoreBreakdown(screen *ebiten.Image) {
+	elapsed := time.Since(g.scoreBreakdownTime)
+	if elapsed >= scoreBreakdownDuration {
+		return
+	}
+	fade := 1 - float64(elapsed)/float64(scoreBreakdownDuration)
+	breakdownMsg := g.scoreBreakdown.String()
+	breakdownWidth, breakdownHeight := getTextSize(breakdownMsg, resources.SmallTextFontFace)
+	const padding = 15
+	boxWidth := float32(breakdownWidth) + 2*padding
+	boxHeight := float32(breakdownHeight) + 2*padding
+	boxX := float32(boardWidth)/2 - boxWidth/2
+	boxY := float32(g.boardY + padding)
+	vector.DrawFilledRect(
+		screen,
+		boxX, boxY,
+		boxWidth, boxHeight,
+		color.RGBA{R: 0, G: 0, B: 0, A: uint8(0x80 * fade)},
+		false,
+	)
+	text.Draw(
+		screen,
+		breakdownMsg,
+		resources.SmallTextFontFace,
+		int(boxX)+padding,
+		int(boxY)+padding+int(breakdownHeight),
+		lerpColor(color.Transparent, green, fade),
+	)
+}
+
 func (g *Game) drawBackground(screen *ebiten.Image) {
 	vector.DrawFilledRect(
 		screen,
@@ -0,0 +1,54 @@
+package game
+
+import (
+	"github.com/mikecoop83/blocks/lib"
+)
+
+// hint is the suggested next placement from the tray.
+type hint struct {
+	slot     int
+	pieceLoc lib.PieceLocation
+}
+
+// rotationHintEvaluator rates plans when the player can rotate pieces.  Trying every rotation multiplies the plans to
+// rate by up to 64, so it leaves out mobility, which is the expensive part of lib.SurvivalEvaluator.
+var rotationHintEvaluator = lib.WeightedEvaluator(lib.Weights{Placed: 1e6, Lines: 1e3, EmptyCells: 1})
+
+// showHint solves the current tray and highlights the first placement of the best plan, turning the piece to match
+// in games with player rotation.  Using a hint marks the game as assisted.
+func (g *Game) showHint() {
+	if g.gameOver {
+		return
+	}
+	evaluate := lib.SurvivalEvaluator
+	if g.session.Config().PlayerRotation {
+		evaluate = rotationHintEvaluator
+	}
+	plan, ok := g.session.Solve(evaluate)
+	if !ok {
+		g.flash("No moves")
+		return
+	}
+	g.hint = &hint{
+		slot:     plan.Slots[0],
+		pieceLoc: plan.Placements[0],
+	}
+	g.rotations[plan.Slots[0]] = plan.Rotations[0]
+	g.assisted = true
+}
+
+// markHint marks the cells the hinted piece should fill.
+func (g *Game) markHint(cells [][]cellState) {
+	if g.hint == nil {
+		return
+	}
+	piece := g.hint.pieceLoc.Piece
+	loc := g.hint.pieceLoc.Loc
+	for r := range piece.Shape {
+		for c := range piece.Shape[r] {
+			if piece.Shape[r][c] {
+				cells[loc.R+r][loc.C+c] = cellHint
+			}
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package game
+
+import (
+	"cmp"
+	"fmt"
+	"image/color"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text"
+
+	"github.com/mikecoop83/blocks/lib"
+	"github.com/mikecoop83/blocks/resources"
+)
+
+const (
+	// leaderboardSize is the number of games kept on the leaderboard.
+	leaderboardSize = 10
+
+	leaderboardRowHeight = 100
+	// screenPadding is the margin of screens drawn instead of the game, such as the leaderboard.
+	screenPadding = 30
+)
+
+// scoreEntry is a game on the leaderboard.
+type scoreEntry struct {
+	Score int64 `json:"score"`
+	// Date is the local date the game was started on.
+	Date   string `json:"date"`
+	GameID uint64 `json:"gameId"`
+	// Config is the rules the game was played with.  It's nil for the entry migrated from when only the high score was
+	// stored, which can't be retried.
+	Config *lib.Config `json:"config,omitempty"`
+	Mode   string      `json:"mode"`
+	// Daily is the date of the daily challenge the game played, or "" for other games.
+	Daily    string `json:"daily,omitempty"`
+	Lines    int    `json:"lines"`
+	Cheated  bool   `json:"cheated,omitempty"`
+	Assisted bool   `json:"assisted,omitempty"`
+	UsedUndo bool   `json:"usedUndo,omitempty"`
+	// Started identifies the game, so that its entry is updated as its score grows rather than added again.
+	Started int64 `json:"started"`
+}
+
+// counts reports whether the entry can be the high score, which games that cheated or used a hint can't.
+func (e scoreEntry) counts() bool {
+	return !e.Cheated && !e.Assisted
+}
+
+// details describes the entry for the leaderboard, such as "endless, 12 lines, undo".
+func (e scoreEntry) details() string {
+	parts := []string{e.Mode, fmt.Sprintf("%d lines", e.Lines)}
+	for _, flag := range []struct {
+		set   bool
+		label string
+	}{
+		{e.Cheated, "cheated"},
+		{e.Assisted, "hint"},
+		{e.UsedUndo, "undo"},
+	} {
+		if flag.set {
+			parts = append(parts, flag.label)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// addScoreEntry returns the leaderboard with the entry added in place of any earlier entry for the same game, keeping
+// the best leaderboardSize entries.
+func addScoreEntry(entries []scoreEntry, entry scoreEntry) []scoreEntry {
+	entries = slices.DeleteFunc(slices.Clone(entries), func(e scoreEntry) bool {
+		return e.Started == entry.Started
+	})
+	entries = append(entries, entry)
+	slices.SortStableFunc(entries, func(a, b scoreEntry) int {
+		return cmp.Compare(b.Score, a.Score)
+	})
+	return entries[:min(len(entries), leaderboardSize)]
+}
+
+// highScoreEntry returns the best entry that counts as a high score, and false if there isn't one.
+func highScoreEntry(entries []scoreEntry) (scoreEntry, bool) {
+	for _, entry := range entries {
+		if entry.counts() {
+			return entry, true
+		}
+	}
+	return scoreEntry{}, false
+}
+
+// loadHighScore sets the high score shown in the header from the leaderboard.
+func (g *Game) loadHighScore() {
+	entry, _ := highScoreEntry(load(leaderboardKey))
+	g.highScore, g.highScoreUsedUndo = entry.Score, entry.UsedUndo
+}
+
+// updateLeaderboard records the game on the leaderboard as its score grows, and again whenever it cheats, uses a hint
+// or takes back a move, even once its score is past its best.  Puzzles have no game ID to retry, so they're left off.
+func (g *Game) updateLeaderboard() {
+	if g.playMode() == modePuzzle {
+		return
+	}
+	entry := g.recorded
+	if g.session.Score() > entry.Score {
+		entry.Score, entry.Lines = g.session.Score(), g.session.Lines()
+	}
+	entry.Cheated, entry.Assisted, entry.UsedUndo = g.cheated, g.assisted, g.session.Undos() > 0
+	if entry.Score == 0 || (entry.Score == g.recorded.Score && entry.Cheated == g.recorded.Cheated &&
+		entry.Assisted == g.recorded.Assisted && entry.UsedUndo == g.recorded.UsedUndo) {
+		return
+	}
+	config := g.session.Config()
+	entry.Date = time.Unix(0, g.started).Format(lib.DailyDateLayout)
+	entry.GameID = g.session.GameID()
+	entry.Config = &config
+	entry.Mode = playModeToName[g.playMode()]
+	entry.Daily = g.daily
+	entry.Started = g.started
+	g.recorded = entry
+	entries := addScoreEntry(load(leaderboardKey), entry)
+	store(leaderboardKey, entries)
+	// The game may have stopped counting as the high score, so it's looked up again.
+	highScore, _ := highScoreEntry(entries)
+	g.highScore, g.highScoreUsedUndo = highScore.Score, highScore.UsedUndo
+}
+
+// retryEntry starts the game of the leaderboard entry over with the same rules.
+func (g *Game) retryEntry(entry scoreEntry) {
+	switch {
+	case entry.Config == nil:
+		g.flash("Can't retry")
+	case entry.Daily != "":
+		g.startDaily(entry.Daily, entry.Config.Version)
+	default:
+		g.startGame(entry.GameID, *entry.Config)
+	}
+}
+
+// drawScreenTitle draws the title of a screen shown instead of the game in the middle of the top area.
+func (g *Game) drawScreenTitle(screen *ebiten.Image, title string) {
+	titleWidth, titleHeight := getTextSize(title, resources.TextFontFace)
+	text.Draw(screen, title, resources.TextFontFace, int((boardWidth-titleWidth)/2),
+		int((topAreaHeight-titleHeight)/2+titleHeight), displayModeToForegroundColor[g.displayMode])
+}
+
+// drawScreenText draws a line of small text with its top at y.
+func drawScreenText(screen *ebiten.Image, msg string, x, y int, clr color.Color) {
+	text.Draw(screen, msg, resources.SmallTextFontFace, x, y+resources.SmallTextFontFace.Metrics().Ascent.Ceil(), clr)
+}
+
+// drawLeaderboard draws the leaderboard over the whole screen.  Tapping an entry retries its game and tapping anywhere
+// else closes the leaderboard.
+func (g *Game) drawLeaderboard(screen *ebiten.Image) {
+	defer func() {
+		g.releaseX, g.releaseY = -1, -1
+	}()
+	foreground := displayModeToForegroundColor[g.displayMode]
+	g.drawScreenTitle(screen, "High scores")
+
+	entries := g.leaderboard
+	if len(entries) == 0 {
+		drawScreenText(screen, "No games yet", screenPadding, topAreaHeight, gray)
+	}
+	for i, entry := range entries {
+		rowY := topAreaHeight + i*leaderboardRowHeight
+		scoreColor, detailsColor := foreground, color.Color(gray)
+		if !entry.counts() {
+			scoreColor, detailsColor = reddishGray, reddishGray
+		}
+		drawScreenText(screen, commaFormatter.Sprintf("%d. %d", i+1, entry.Score), screenPadding, rowY, scoreColor)
+		dateWidth, _ := getTextSize(entry.Date, resources.SmallTextFontFace)
+		drawScreenText(screen, entry.Date, boardWidth-screenPadding-int(dateWidth), rowY, gray)
+		drawScreenText(screen, entry.details(), screenPadding, rowY+leaderboardRowHeight/2, detailsColor)
+	}
+
+	if g.releaseX < 0 || g.releaseY < 0 {
+		return
+	}
+	g.leaderboardOpen = false
+	if i := (g.releaseY - topAreaHeight) / leaderboardRowHeight; g.releaseY >= topAreaHeight && i < len(entries) {
+		g.retryEntry(entries[i])
+	}
+}
@@ -0,0 +1,212 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mikecoop83/blocks/lib"
+)
+
+// Link is what a shared game link carries: the game ID, which determines the pieces dealt, and the choices that
+// change how they can be played.
+type Link struct {
+	GameID uint64
+	// Version is how the game ID turns into pieces.  Links from before versions existed don't carry one, so they get
+	// lib.RulesLegacy and keep dealing the pieces they always did.
+	Version lib.RulesVersion
+	// Width and Height are the size of the board, or zero for the default one, which links from before sizes were
+	// carried were all played on.
+	Width, Height int
+	// Scoring is the scoring rules the game is played with.
+	Scoring lib.ScoringRules
+	// Pieces names the piece set dealt from, or "" for the classic set.
+	Pieces string
+	// Distribution is how pieces are drawn from the set.
+	Distribution lib.PieceDistribution
+	// Rotation is set for games in which the player rotates pieces.
+	Rotation bool
+	// ColorBonus is set for games that score a bonus for single-color lines.
+	ColorBonus bool
+	// Boxes is set for games that clear full boxes as well as full lines.
+	Boxes bool
+	// Daily is the date of the daily challenge the link points to, or "" for other games.  Daily links carry the date
+	// instead of the game ID, which is derived from it.
+	Daily string
+	// Level is the name of the level the link points to, or "" for other games.  Levels don't use a game ID.
+	Level string
+}
+
+var errNoGameID = errors.New("no game ID in link")
+
+// ParseLink reads a link from a full URL, its query string or just a game ID, in words or hex.
+func ParseLink(text string) (Link, error) {
+	text = strings.TrimSpace(text)
+	if i := strings.Index(text, "?"); i >= 0 {
+		text = text[i+1:]
+	}
+	if !strings.Contains(text, "=") {
+		gameID, err := lib.ParseGameID(text)
+		return Link{GameID: gameID}, err
+	}
+	values, err := url.ParseQuery(text)
+	if err != nil {
+		return Link{}, err
+	}
+	return parseLinkValues(values)
+}
+
+func parseLinkValues(values url.Values) (Link, error) {
+	link := Link{
+		Rotation:   values.Get("rotate") == "1",
+		ColorBonus: values.Get("bonus") == "1",
+		Boxes:      values.Get("boxes") == "1",
+	}
+	if versionText := values.Get("v"); versionText != "" {
+		version, err := strconv.Atoi(versionText)
+		if err != nil {
+			return Link{}, err
+		}
+		link.Version = lib.RulesVersion(version)
+	}
+	if sizeText := values.Get("size"); sizeText != "" {
+		size, ok := parseBoardSize(sizeText)
+		if !ok {
+			return Link{}, fmt.Errorf("unknown board size %q", sizeText)
+		}
+		link.Width, link.Height = size.Width, size.Height
+	}
+	if scoringText := values.Get("scoring"); scoringText != "" {
+		index := slices.IndexFunc(lib.AllScoringRules, func(rules lib.ScoringRules) bool {
+			return rules.String() == scoringText
+		})
+		if index < 0 {
+			return Link{}, fmt.Errorf("unknown scoring rules %q", scoringText)
+		}
+		link.Scoring = lib.AllScoringRules[index]
+	}
+	if pieces := values.Get("pieces"); pieces != "" && pieces != lib.ClassicPieceSet {
+		_, err := lib.LookupPieceSet(pieces)
+		if err != nil {
+			return Link{}, err
+		}
+		link.Pieces = pieces
+	}
+	if distributionText := values.Get("dealing"); distributionText != "" {
+		index := slices.IndexFunc(lib.AllPieceDistributions, func(distribution lib.PieceDistribution) bool {
+			return distribution.String() == distributionText
+		})
+		if index < 0 {
+			return Link{}, fmt.Errorf("unknown piece distribution %q", distributionText)
+		}
+		link.Distribution = lib.AllPieceDistributions[index]
+	}
+	if level := values.Get("level"); level != "" {
+		link.Level = level
+		return link, nil
+	}
+	if daily := values.Get("daily"); daily != "" {
+		gameID, err := lib.DailyGameID(daily)
+		if err != nil {
+			return Link{}, err
+		}
+		link.GameID = gameID
+		link.Daily = daily
+		return link, nil
+	}
+	gameIDText := values.Get("game")
+	if gameIDText == "" {
+		return Link{}, errNoGameID
+	}
+	gameID, err := lib.ParseGameID(gameIDText)
+	if err != nil {
+		return Link{}, err
+	}
+	link.GameID = gameID
+	return link, nil
+}
+
+// Query encodes the link as URL query parameters.
+func (l Link) Query() url.Values {
+	query := url.Values{}
+	if l.Level != "" {
+		query.Set("level", l.Level)
+		return query
+	}
+	if l.Daily != "" {
+		query.Set("daily", l.Daily)
+	} else {
+		query.Set("game", lib.FormatGameID(l.GameID))
+	}
+	if l.Version != lib.RulesLegacy {
+		query.Set("v", strconv.Itoa(int(l.Version)))
+	}
+	if size := (BoardSize{Width: l.Width, Height: l.Height}); l.Width != 0 && size != boardSizes[0] {
+		query.Set("size", size.String())
+	}
+	if l.Scoring != lib.ScoringClassic {
+		query.Set("scoring", l.Scoring.String())
+	}
+	if l.Pieces != "" {
+		query.Set("pieces", l.Pieces)
+	}
+	if l.Distribution != lib.DistributionUniform {
+		query.Set("dealing", l.Distribution.String())
+	}
+	if l.Rotation {
+		query.Set("rotate", "1")
+	}
+	if l.ColorBonus {
+		query.Set("bonus", "1")
+	}
+	if l.Boxes {
+		query.Set("boxes", "1")
+	}
+	return query
+}
+
+// config is the rules of the game the link points to, which come from the link alone so that everyone opening it
+// plays the same game whatever their settings.
+func (l Link) config() lib.Config {
+	config := lib.Config{
+		Version:        l.Version,
+		Width:          l.Width,
+		Height:         l.Height,
+		Scoring:        l.Scoring,
+		Pieces:         l.Pieces,
+		Distribution:   l.Distribution,
+		PlayerRotation: l.Rotation,
+		ColorBonus:     l.ColorBonus,
+		Boxes:          l.Boxes,
+	}
+	if l.Width == 0 {
+		// Links with boxes from before sizes were carried were played on the board for boxes.
+		size := boardSizes[0]
+		if l.Boxes {
+			size = boxesBoardSize
+		}
+		config.Width, config.Height = size.Width, size.Height
+	}
+	return config
+}
+
+// link is the link to the game being played.
+func (g *Game) link() Link {
+	return Link{
+		GameID:       g.session.GameID(),
+		Version:      g.session.Config().Version,
+		Width:        g.session.Config().Width,
+		Height:       g.session.Config().Height,
+		Scoring:      g.session.Config().Scoring,
+		Pieces:       g.session.Config().Pieces,
+		Distribution: g.session.Config().Distribution,
+		Rotation:     g.session.Config().PlayerRotation,
+		ColorBonus:   g.session.Config().ColorBonus,
+		Boxes:        g.session.Config().Boxes,
+		Daily:        g.daily,
+		Level:        g.session.Config().Level,
+	}
+}
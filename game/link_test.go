@@ -0,0 +1,51 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/mikecoop83/blocks/lib"
+	"github.com/mikecoop83/blocks/persist"
+)
+
+func TestLinkQuery(t *testing.T) {
+	for name, link := range map[string]Link{
+		"default": {GameID: 1234, Version: lib.CurrentRulesVersion},
+		"custom": {
+			GameID:       1234,
+			Version:      lib.CurrentRulesVersion,
+			Width:        10,
+			Height:       10,
+			Scoring:      lib.ScoringCombo,
+			Pieces:       "tetrominoes",
+			Rotation:     true,
+			ColorBonus:   true,
+			Distribution: lib.DistributionWeighted,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			parsed, err := ParseLink("https://example.com/?" + link.Query().Encode())
+			require.NoError(t, err)
+			require.Equal(t, link, parsed)
+		})
+	}
+
+	// Everything about the game comes from the link, none of it from the settings.
+	defer persist.SetBackend(persist.SetBackend(persist.NewMemory()))
+	store(boardSizeKey, "10x10")
+	store(scoringKey, lib.ScoringCombo.String())
+	store(colorBonusKey, true)
+	store(boxesKey, true)
+	link, err := ParseLink("game=1234&v=1")
+	require.NoError(t, err)
+	require.Equal(t, lib.DefaultConfig, link.config())
+	link, err = ParseLink("game=1234&boxes=1")
+	require.NoError(t, err)
+	require.Equal(t, lib.Config{Width: 9, Height: 9, Boxes: true}, link.config())
+
+	_, err = ParseLink("game=1234&size=7x7")
+	require.Error(t, err)
+	_, err = ParseLink("game=1234&pieces=missing")
+	require.Error(t, err)
+}
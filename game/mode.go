@@ -0,0 +1,77 @@
+package game
+
+import (
+	"math/rand"
+
+	"github.com/mikecoop83/blocks/lib"
+)
+
+// playMode is the kind of game being played.
+type playMode int
+
+const (
+	modeEndless playMode = iota
+	modeDaily
+	modePuzzle
+	numPlayModes
+)
+
+var playModeToName = map[playMode]string{
+	modeEndless: "endless",
+	modeDaily:   "daily",
+	modePuzzle:  "puzzle",
+}
+
+// playMode returns the mode of the game being played.
+func (g *Game) playMode() playMode {
+	switch {
+	case g.daily != "":
+		return modeDaily
+	case g.session.Config().Level != "":
+		return modePuzzle
+	default:
+		return modeEndless
+	}
+}
+
+// switchPlayMode starts a game in the next mode.
+func (g *Game) switchPlayMode() {
+	switch (g.playMode() + 1) % numPlayModes {
+	case modeDaily:
+		g.startToday()
+	case modePuzzle:
+		g.startLevel(firstUnsolvedLevel())
+	default:
+		g.Reset(rand.Uint64())
+	}
+}
+
+// newGame starts another game in the mode being played.  In puzzle mode that's the next level once the current one is
+// solved and the same level again until then.
+func (g *Game) newGame() {
+	switch g.playMode() {
+	case modeDaily:
+		g.startToday()
+	case modePuzzle:
+		index := g.levelIndex()
+		if g.session.Solved() {
+			index = (index + 1) % len(lib.Levels())
+		}
+		g.startLevel(index)
+	default:
+		g.Reset(rand.Uint64())
+	}
+}
+
+// modeLabel is shown in the middle of the header: whether a daily challenge is scored, or the progress towards a
+// level's goal.
+func (g *Game) modeLabel() string {
+	switch g.playMode() {
+	case modeDaily:
+		return g.dailyLabel()
+	case modePuzzle:
+		return g.puzzleLabel()
+	default:
+		return ""
+	}
+}
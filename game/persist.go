@@ -1,32 +1,157 @@
 package game
 
 import (
+	"errors"
 	"log/slog"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/mikecoop83/blocks/lib"
 	"github.com/mikecoop83/blocks/persist"
 )
 
-func maybeGetHighScore() int64 {
-	highScoreStr, err := persist.Load("highscore")
+// The keys the game stores its data under.  Daily results and solved levels have a key each, from dailyKey and
+// levelKey.
+var (
+	boardSizeKey    = persist.Key[string]("boardsize")
+	displayModeKey  = persist.Key[string]("displaymode")
+	undoPolicyKey   = persist.Key[string]("undopolicy")
+	scoringKey      = persist.Key[string]("scoring")
+	pieceSetKey     = persist.Key[string]("pieceset")
+	distributionKey = persist.Key[string]("distribution")
+	rotationKey     = persist.Key[bool]("rotation")
+	colorBonusKey   = persist.Key[bool]("colorbonus")
+	boxesKey        = persist.Key[bool]("boxes")
+	savedGameKey    = persist.Key[savedGame]("savedgame")
+	leaderboardKey  = persist.Key[[]scoreEntry]("leaderboard")
+	statsKey        = persist.Key[lifetimeStats]("stats")
+)
+
+// load returns the value stored under the key, or the zero value if there isn't one or it can't be read.
+func load[T any](key persist.Key[T]) T {
+	value, err := key.Load()
+	if err != nil && !errors.Is(err, persist.ErrNotFound) {
+		slog.Error("failed to load", "key", string(key), "error", err)
+	}
+	return value
+}
+
+// store stores the value under the key, logging any error.
+func store[T any](key persist.Key[T], value T) {
+	err := key.Store(value)
+	if err != nil {
+		slog.Error("failed to store", "key", string(key), "error", err)
+	}
+}
+
+// migrations bring the stored data up to date with the current schema version.
+var migrations = []persist.Migration{
+	{Version: 1, Migrate: migrateToJSON},
+}
+
+// migrateToJSON encodes the plain strings stored before values were JSON as JSON, turning the settings that are on or
+// off into booleans, and moves the single high score stored before there was a leaderboard onto it.
+func migrateToJSON() error {
+	keys, err := persist.Keys()
 	if err != nil {
-		slog.Error("failed to load high score: %v", err)
-		return 0
+		return err
+	}
+	var highScore int64
+	var highScoreUsedUndo bool
+	for _, key := range keys {
+		value, err := persist.Load(key)
+		if err != nil {
+			return err
+		}
+		switch {
+		case key == "highscore":
+			highScore, _ = strconv.ParseInt(value, 10, 64)
+		case key == "highscoreundo":
+			highScoreUsedUndo = value == "true"
+		case key == string(rotationKey):
+			err = rotationKey.Store(value == "player")
+		case key == string(colorBonusKey) || key == string(boxesKey):
+			err = persist.Key[bool](key).Store(value == "on")
+		case strings.HasPrefix(key, levelKeyPrefix):
+			err = persist.Key[bool](key).Store(value == "solved")
+		case key == string(savedGameKey) && value == "":
+			// The saved game used to be cleared by storing "" over it.
+			err = savedGameKey.Delete()
+		case key == string(boardSizeKey) || key == string(displayModeKey) || key == string(undoPolicyKey) ||
+			key == string(scoringKey) || key == string(pieceSetKey) || key == string(distributionKey):
+			err = persist.Key[string](key).Store(value)
+		}
+		// Daily results are numbers, which are already JSON, and the saved game, leaderboard and stats were already
+		// stored as JSON.
+		if err != nil {
+			return err
+		}
+	}
+	if highScore > 0 {
+		entries := addScoreEntry(load(leaderboardKey), scoreEntry{
+			Score:    highScore,
+			Mode:     playModeToName[modeEndless],
+			UsedUndo: highScoreUsedUndo,
+		})
+		err = leaderboardKey.Store(entries)
+		if err != nil {
+			return err
+		}
 	}
-	if highScoreStr == "" {
-		return 0
+	for _, key := range []string{"highscore", "highscoreundo"} {
+		err = persist.Delete(key)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// savedGame is an in-progress game stored so it can be continued after a restart.  The record is replayed to restore
+// the board, tray, score and the position of the random source.
+type savedGame struct {
+	Record   lib.Record `json:"record"`
+	Cheated  bool       `json:"cheated"`
+	Assisted bool       `json:"assisted"`
+	// Daily is the date of the daily challenge the game plays, and Practice is set if it isn't the scored attempt.
+	Daily    string `json:"daily,omitempty"`
+	Practice bool   `json:"practice,omitempty"`
+	// Started identifies the game's leaderboard entry, and Played is how long it had been played.  Counted is what had
+	// been added to the stats for it, if it had been taken back past its end.
+	Started int64         `json:"started,omitempty"`
+	Played  time.Duration `json:"played,omitempty"`
+	Counted *countedGame  `json:"counted,omitempty"`
+}
+
+func (g *Game) saveGame() {
+	saved := savedGame{
+		Record:   g.session.Record(),
+		Cheated:  g.cheated,
+		Assisted: g.assisted,
+		Daily:    g.daily,
+		Practice: g.practice,
+		Started:  g.started,
+		Played:   time.Since(g.playStart),
+		Counted:  g.counted,
 	}
-	highScore, err := strconv.ParseInt(highScoreStr, 10, 64)
+	store(savedGameKey, saved)
+}
+
+func loadSavedGame() *savedGame {
+	saved, err := savedGameKey.Load()
 	if err != nil {
-		slog.Error("failed to parse high score: %v", err)
-		return 0
+		if !errors.Is(err, persist.ErrNotFound) {
+			slog.Error("failed to load saved game", "error", err)
+		}
+		return nil
 	}
-	return highScore
+	return &saved
 }
 
-func maybeUpdateHighScore(highScore int64) {
-	err := persist.Store("highscore", strconv.FormatInt(highScore, 10))
+func clearSavedGame() {
+	err := savedGameKey.Delete()
 	if err != nil {
-		slog.Error("failed to save high score: %v", err)
+		slog.Error("failed to clear saved game", "error", err)
 	}
 }
@@ -0,0 +1,74 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/mikecoop83/blocks/persist"
+)
+
+func TestMigrateToJSON(t *testing.T) {
+	defer persist.SetBackend(persist.SetBackend(persist.NewMemory()))
+	for key, value := range map[string]string{
+		"boardsize":        "10x10",
+		"rotation":         "player",
+		"colorbonus":       "off",
+		"level-crossroads": "solved",
+		"daily-2026-10-18": "420",
+		"savedgame":        "",
+		"highscore":        "1234",
+		"highscoreundo":    "true",
+	} {
+		require.NoError(t, persist.Store(key, value))
+	}
+	require.NoError(t, persist.Migrate(migrations))
+
+	require.Equal(t, "10x10", load(boardSizeKey))
+	require.True(t, load(rotationKey))
+	require.False(t, load(colorBonusKey))
+	require.True(t, loadLevelSolved("crossroads"))
+	best, played := loadDailyBest("2026-10-18")
+	require.True(t, played)
+	require.Equal(t, int64(420), best)
+	require.Nil(t, loadSavedGame())
+	require.Equal(t, []scoreEntry{{Score: 1234, Mode: "endless", UsedUndo: true}}, load(leaderboardKey))
+	_, err := persist.Load("highscore")
+	require.ErrorIs(t, err, persist.ErrNotFound)
+
+	// Migrating again leaves the data alone.
+	require.NoError(t, persist.Migrate(migrations))
+	require.True(t, load(rotationKey))
+}
+
+func TestImportSaveData(t *testing.T) {
+	defer persist.SetBackend(persist.SetBackend(persist.NewMemory()))
+	require.NoError(t, persist.Migrate(migrations))
+	store(boardSizeKey, "10x10")
+	store(leaderboardKey, []scoreEntry{{Score: 300, Started: 1}})
+	store(dailyKey("2026-10-17"), 50)
+	store(dailyKey("2026-10-18"), 500)
+	store(levelKey("crossroads"), true)
+	text, err := persist.Export(isGameKey)
+	require.NoError(t, err)
+
+	persist.SetBackend(persist.NewMemory())
+	require.NoError(t, persist.Migrate(migrations))
+	store(boardSizeKey, "6x6")
+	store(leaderboardKey, []scoreEntry{{Score: 400, Started: 2}})
+	store(dailyKey("2026-10-17"), 70)
+	require.NoError(t, persist.Store("other", "not the game's"))
+	require.NoError(t, importSaveData(text))
+
+	require.Equal(t, "10x10", load(boardSizeKey))
+	require.Equal(t, []scoreEntry{{Score: 400, Started: 2}, {Score: 300, Started: 1}}, load(leaderboardKey))
+	require.Equal(t, int64(70), load(dailyKey("2026-10-17")))
+	require.Equal(t, int64(500), load(dailyKey("2026-10-18")))
+	require.True(t, loadLevelSolved("crossroads"))
+
+	// Importing the same data again changes nothing.
+	require.NoError(t, importSaveData(text))
+	require.Len(t, load(leaderboardKey), 2)
+
+	require.ErrorIs(t, importSaveData("blocks1:damaged"), persist.ErrInvalidExport)
+}
@@ -0,0 +1,100 @@
+package game
+
+import (
+	"fmt"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text"
+	"github.com/mikecoop83/blocks/lib"
+	"github.com/mikecoop83/blocks/persist"
+	"github.com/mikecoop83/blocks/resources"
+)
+
+// startLevel starts the built-in level with the index.
+func (g *Game) startLevel(index int) {
+	g.startGame(0, lib.Levels()[index].Config())
+}
+
+// levelIndex returns the index of the level being played, or 0 outside puzzle mode.
+func (g *Game) levelIndex() int {
+	for i, level := range lib.Levels() {
+		if level.Name == g.session.Config().Level {
+			return i
+		}
+	}
+	return 0
+}
+
+// firstUnsolvedLevel returns the index of the first level that hasn't been solved, or 0 once they all have.
+func firstUnsolvedLevel() int {
+	for i, level := range lib.Levels() {
+		if !loadLevelSolved(level.Name) {
+			return i
+		}
+	}
+	return 0
+}
+
+// levelKey is the key that records whether the level with the name has been solved.
+func levelKey(name string) persist.Key[bool] {
+	return persist.Key[bool](levelKeyPrefix + name)
+}
+
+func loadLevelSolved(name string) bool {
+	return load(levelKey(name))
+}
+
+// recordSolvedLevel records that the level being played was solved, unless it took cheating.
+func (g *Game) recordSolvedLevel() {
+	level, ok := g.session.Level()
+	if !ok || !g.session.Solved() || g.cheated {
+		return
+	}
+	store(levelKey(level.Name), true)
+}
+
+// levelMenuLabel names the level being played in the menu, marking it if it has been solved.
+func (g *Game) levelMenuLabel() string {
+	index := g.levelIndex()
+	label := fmt.Sprintf("Level %d/%d", index+1, len(lib.Levels()))
+	if loadLevelSolved(lib.Levels()[index].Name) {
+		label += " (done)"
+	}
+	return label
+}
+
+// puzzleLabel shows the progress towards the goal of the level being played.
+func (g *Game) puzzleLabel() string {
+	level, ok := g.session.Level()
+	if !ok {
+		return ""
+	}
+	switch level.Goal.Kind {
+	case lib.GoalClearBoard:
+		return fmt.Sprintf("%d blocks left", g.session.Board().Occupancy().Count())
+	case lib.GoalLines:
+		return fmt.Sprintf("Lines %d/%d", min(g.session.Lines(), level.Goal.Target), level.Goal.Target)
+	default:
+		return level.Goal.String()
+	}
+}
+
+// drawPiecesLeft shows how many of a level's pieces are left to place below the tray.
+func (g *Game) drawPiecesLeft(screen *ebiten.Image) {
+	if g.playMode() != modePuzzle {
+		return
+	}
+	piecesLeftMsg := fmt.Sprintf("%d pieces left", g.session.PiecesLeft())
+	if g.session.PiecesLeft() == 1 {
+		piecesLeftMsg = "1 piece left"
+	}
+	piecesLeftWidth, _ := getTextSize(piecesLeftMsg, resources.SmallTextFontFace)
+	text.Draw(
+		screen,
+		piecesLeftMsg,
+		resources.SmallTextFontFace,
+		int((boardWidth-piecesLeftWidth)/2),
+		WindowHeight-20,
+		displayModeToForegroundColor[g.displayMode],
+	)
+}
@@ -0,0 +1,64 @@
+package game
+
+import (
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/mikecoop83/blocks/lib"
+)
+
+// rotateKey turns the selected piece in games with player rotation.  R is taken by reset.
+const rotateKey = ebiten.KeySpace
+
+// trayPiece returns the piece in the slot as the player has turned it, or nil if the slot is empty.
+func (g *Game) trayPiece(slot int) *lib.Piece {
+	piece := g.session.Tray()[slot]
+	if piece == nil || g.rotations[slot] == 0 {
+		return piece
+	}
+	rotated := *piece
+	for range g.rotations[slot] {
+		rotated = rotated.Rotate()
+	}
+	return &rotated
+}
+
+// updateRotation turns the piece being dragged, or the tray piece under the cursor, on the rotate key, the mouse wheel,
+// a right click or a second finger tapping while the first drags.
+func (g *Game) updateRotation(pressedTouchIDs, dragTouchIDs []ebiten.TouchID) {
+	if !g.session.Config().PlayerRotation || g.gameOver {
+		return
+	}
+	var turns int
+	if inpututil.IsKeyJustPressed(rotateKey) || inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonRight) {
+		turns = 1
+	}
+	if _, dy := ebiten.Wheel(); dy > 0 {
+		turns = 1
+	} else if dy < 0 {
+		turns = 3
+	}
+	if len(pressedTouchIDs) > 0 && len(dragTouchIDs) == 2 {
+		turns = 1
+	}
+	if turns == 0 {
+		return
+	}
+	slot := g.chosenPieceIdx
+	if slot < 0 && !g.touchEnabled {
+		slot = traySlotAt(ebiten.CursorPosition())
+	}
+	if slot < 0 || g.session.Tray()[slot] == nil {
+		return
+	}
+	g.rotations[slot] = (g.rotations[slot] + turns) % 4
+	g.hint = nil
+}
+
+// traySlotAt returns the tray slot whose area contains the point, or -1 if it's outside the tray.
+func traySlotAt(x, y int) int {
+	const bottomAreaOffset = topAreaHeight + boardHeight
+	if y < bottomAreaOffset || y >= bottomAreaOffset+bottomAreaHeight || x < 0 || x >= boardWidth {
+		return -1
+	}
+	return x / (boardWidth / numPieceOptions)
+}
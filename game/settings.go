@@ -0,0 +1,144 @@
+package game
+
+import (
+	"math/rand"
+
+	"github.com/mikecoop83/blocks/lib"
+)
+
+// loadConfig builds the rules for a new game from the stored settings.
+func loadConfig() lib.Config {
+	boardSize := loadBoardSize()
+	config := lib.Config{
+		Version:        lib.CurrentRulesVersion,
+		Width:          boardSize.Width,
+		Height:         boardSize.Height,
+		Scoring:        loadScoringRules(),
+		Pieces:         loadPieceSet(),
+		Distribution:   loadPieceDistribution(),
+		PlayerRotation: load(rotationKey),
+		ColorBonus:     load(colorBonusKey),
+	}
+	return withBoxes(config, load(boxesKey))
+}
+
+// withBoxes returns the config with boxes turned on or off.  Boards that can't be split into boxes are swapped for
+// boxesBoardSize when they're on.
+func withBoxes(config lib.Config, boxes bool) lib.Config {
+	config.Boxes = boxes
+	if boxes && !(BoardSize{Width: config.Width, Height: config.Height}).fitsBoxes() {
+		config.Width, config.Height = boxesBoardSize.Width, boxesBoardSize.Height
+	}
+	return config
+}
+
+// switchBoxes toggles whether full boxes are cleared and starts a new game with the choice.
+func (g *Game) switchBoxes() {
+	store(boxesKey, !g.session.Config().Boxes)
+	g.Reset(rand.Uint64())
+}
+
+func onOffLabel(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
+
+// switchColorBonus toggles the bonus for single-color lines and starts a new game with the choice.
+func (g *Game) switchColorBonus() {
+	store(colorBonusKey, !g.session.Config().ColorBonus)
+	g.Reset(rand.Uint64())
+}
+
+func rotationLabel(playerRotation bool) string {
+	if playerRotation {
+		return "player"
+	}
+	return "random"
+}
+
+// switchPlayerRotation toggles whether the player rotates pieces and starts a new game with the choice.
+func (g *Game) switchPlayerRotation() {
+	store(rotationKey, !g.session.Config().PlayerRotation)
+	g.Reset(rand.Uint64())
+}
+
+func loadPieceDistribution() lib.PieceDistribution {
+	distributionText := load(distributionKey)
+	for _, distribution := range lib.AllPieceDistributions {
+		if distribution.String() == distributionText {
+			return distribution
+		}
+	}
+	return lib.DistributionUniform
+}
+
+// switchPieceDistribution cycles to the next piece distribution and starts a new game with it.
+func (g *Game) switchPieceDistribution() {
+	next := lib.AllPieceDistributions[0]
+	for i, distribution := range lib.AllPieceDistributions {
+		if distribution == g.session.Config().Distribution {
+			next = lib.AllPieceDistributions[(i+1)%len(lib.AllPieceDistributions)]
+			break
+		}
+	}
+	store(distributionKey, next.String())
+	g.Reset(rand.Uint64())
+}
+
+// loadPieceSet returns the name of the stored piece set, or "" for the classic set.
+func loadPieceSet() string {
+	name := load(pieceSetKey)
+	if name == lib.ClassicPieceSet {
+		return ""
+	}
+	if _, err := lib.LookupPieceSet(name); err != nil {
+		return ""
+	}
+	return name
+}
+
+func pieceSetLabel(name string) string {
+	if name == "" {
+		return lib.ClassicPieceSet
+	}
+	return name
+}
+
+// switchPieceSet cycles to the next piece set and starts a new game with it.
+func (g *Game) switchPieceSet() {
+	current := pieceSetLabel(g.session.Config().Pieces)
+	next := lib.PieceSetNames[0]
+	for i, name := range lib.PieceSetNames {
+		if name == current {
+			next = lib.PieceSetNames[(i+1)%len(lib.PieceSetNames)]
+			break
+		}
+	}
+	store(pieceSetKey, next)
+	g.Reset(rand.Uint64())
+}
+
+func loadScoringRules() lib.ScoringRules {
+	scoringText := load(scoringKey)
+	for _, rules := range lib.AllScoringRules {
+		if rules.String() == scoringText {
+			return rules
+		}
+	}
+	return lib.ScoringClassic
+}
+
+// switchScoringRules cycles to the next scoring rules and starts a new game with them.
+func (g *Game) switchScoringRules() {
+	next := lib.AllScoringRules[0]
+	for i, rules := range lib.AllScoringRules {
+		if rules == g.session.Config().Scoring {
+			next = lib.AllScoringRules[(i+1)%len(lib.AllScoringRules)]
+			break
+		}
+	}
+	store(scoringKey, next.String())
+	g.Reset(rand.Uint64())
+}
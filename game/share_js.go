@@ -6,6 +6,9 @@ import (
 	"syscall/js"
 )
 
+// exportedMessage is flashed once save data is exported.
+const exportedMessage = "Copied!"
+
 func copyToClipboard(text string) {
 	navigator := js.Global().Get("navigator")
 	if !navigator.Get("clipboard").IsUndefined() {
@@ -15,5 +18,20 @@ func copyToClipboard(text string) {
 
 func getGameURL() string {
 	location := js.Global().Get("window").Get("location")
-	return location.Get("origin").String() + location.Get("pathname").String() + "?game=" + location.Get("search").String()[5:]
+	return location.Get("origin").String() + location.Get("pathname").String() + location.Get("search").String()
+}
+
+// writeExport copies exported save data to the clipboard.
+func writeExport(text string) error {
+	copyToClipboard(text)
+	return nil
+}
+
+// readImport asks the player to paste save data to import.  It returns "" if they cancel.
+func readImport() (string, error) {
+	text := js.Global().Call("prompt", "Paste the save data to import")
+	if text.IsNull() {
+		return "", nil
+	}
+	return text.String(), nil
 }
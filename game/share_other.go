@@ -2,6 +2,19 @@
 
 package game
 
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// exportedMessage is flashed once save data is exported.
+const exportedMessage = "Exported"
+
+// exportFileName is the file in the home directory save data is exported to and imported from.
+const exportFileName = "blocks-save.txt"
+
 func getGameURL() string {
 	return ""
 }
@@ -9,3 +22,34 @@ func getGameURL() string {
 func copyToClipboard(text string) {
 	// No-op for non-JS platforms
 }
+
+func exportPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, exportFileName), nil
+}
+
+// writeExport writes exported save data to the export file, to be copied to another device.
+func writeExport(text string) error {
+	path, err := exportPath()
+	if err != nil {
+		return err
+	}
+	slog.Info("exporting save data", "path", path)
+	return os.WriteFile(path, []byte(text+"\n"), 0o644)
+}
+
+// readImport reads save data to import from the export file.
+func readImport() (string, error) {
+	path, err := exportPath()
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
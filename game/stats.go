@@ -0,0 +1,139 @@
+package game
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+
+	"github.com/mikecoop83/blocks/lib"
+)
+
+const statsRowHeight = 60
+
+// lifetimeStats are totals over every game played.  Moves count as they're placed, so moves that are later undone
+// still count, and games count once they end or are left for another game.
+type lifetimeStats struct {
+	GamesPlayed int   `json:"gamesPlayed"`
+	TotalScore  int64 `json:"totalScore"`
+	// BestMove is the most points scored by a single placement.
+	BestMove      int `json:"bestMove"`
+	Lines         int `json:"lines"`
+	PerfectClears int `json:"perfectClears"`
+	// PiecesPlaced counts the pieces placed by name.
+	PiecesPlaced map[string]int `json:"piecesPlaced,omitempty"`
+	// TotalDuration is the time spent playing the counted games.
+	TotalDuration time.Duration `json:"totalDuration"`
+}
+
+// recordMove adds a placement of a tray piece to the stats.
+func recordMove(placement lib.Placement) {
+	stats := load(statsKey)
+	if stats.PiecesPlaced == nil {
+		stats.PiecesPlaced = make(map[string]int)
+	}
+	stats.PiecesPlaced[placement.Piece.Name]++
+	stats.BestMove = max(stats.BestMove, placement.Points)
+	stats.Lines += placement.NumClearedLines()
+	if placement.Breakdown.EmptyBoard > 0 {
+		stats.PerfectClears++
+	}
+	store(statsKey, stats)
+}
+
+// countedGame is the score and play time of a game as last added to the stats.
+type countedGame struct {
+	Score  int64         `json:"score"`
+	Played time.Duration `json:"played"`
+}
+
+// countGame adds a game with the score and play time to the stats.  counted is what was added for it before, or nil if
+// it hasn't been counted, so a game that goes on after being counted only adds what changed since.  It returns what's
+// now counted for the game.
+func countGame(counted *countedGame, score int64, played time.Duration) *countedGame {
+	stats := load(statsKey)
+	if counted == nil {
+		stats.GamesPlayed++
+		counted = &countedGame{}
+	}
+	stats.TotalScore += score - counted.Score
+	stats.TotalDuration += played - counted.Played
+	store(statsKey, stats)
+	return &countedGame{Score: score, Played: played}
+}
+
+// recordGame adds the game being played to the stats when it ends or is left, if any moves were made.  A game taken
+// back past its end is counted again when it next ends, updating its totals.
+func (g *Game) recordGame() {
+	if g.session == nil || len(g.session.Moves()) == 0 {
+		return
+	}
+	g.counted = countGame(g.counted, g.session.Score(), time.Since(g.playStart))
+}
+
+// dropSavedGame adds the saved game offered to be continued to the stats and forgets it, since another game is being
+// played instead.
+func (g *Game) dropSavedGame() {
+	saved := g.savedGame
+	if saved == nil {
+		return
+	}
+	g.savedGame = nil
+	clearSavedGame()
+	if len(saved.Record.Moves) > 0 {
+		countGame(saved.Counted, saved.Record.Score, saved.Played)
+	}
+}
+
+// statsLines are the totals shown on the stats screen.
+func (s lifetimeStats) statsLines() []string {
+	var averageScore int64
+	var averageDuration time.Duration
+	if s.GamesPlayed > 0 {
+		averageScore = s.TotalScore / int64(s.GamesPlayed)
+		averageDuration = s.TotalDuration / time.Duration(s.GamesPlayed)
+	}
+	return []string{
+		commaFormatter.Sprintf("Games played: %d", s.GamesPlayed),
+		commaFormatter.Sprintf("Total score: %d", s.TotalScore),
+		commaFormatter.Sprintf("Average score: %d", averageScore),
+		commaFormatter.Sprintf("Best move: %d", s.BestMove),
+		commaFormatter.Sprintf("Lines cleared: %d", s.Lines),
+		commaFormatter.Sprintf("Perfect clears: %d", s.PerfectClears),
+		fmt.Sprintf("Average game length: %s", averageDuration.Round(time.Second)),
+	}
+}
+
+// drawStats draws the stats over the whole screen, followed by the most placed pieces in two columns.  Tapping
+// anywhere closes it.
+func (g *Game) drawStats(screen *ebiten.Image) {
+	defer func() {
+		g.releaseX, g.releaseY = -1, -1
+	}()
+	foreground := displayModeToForegroundColor[g.displayMode]
+	g.drawScreenTitle(screen, "Stats")
+
+	y := topAreaHeight
+	for _, line := range g.stats.statsLines() {
+		drawScreenText(screen, line, screenPadding, y, foreground)
+		y += statsRowHeight
+	}
+
+	y += statsRowHeight / 2
+	names := slices.SortedFunc(maps.Keys(g.stats.PiecesPlaced), func(a, b string) int {
+		return cmp.Or(cmp.Compare(g.stats.PiecesPlaced[b], g.stats.PiecesPlaced[a]), cmp.Compare(a, b))
+	})
+	rows := (WindowHeight - y) / statsRowHeight
+	for i, name := range names[:min(len(names), 2*rows)] {
+		x := screenPadding + (i%2)*boardWidth/2
+		drawScreenText(screen, commaFormatter.Sprintf("%s: %d", name, g.stats.PiecesPlaced[name]), x,
+			y+(i/2)*statsRowHeight, gray)
+	}
+
+	if g.releaseX >= 0 && g.releaseY >= 0 {
+		g.statsOpen = false
+	}
+}
@@ -0,0 +1,20 @@
+package game
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/mikecoop83/blocks/persist"
+)
+
+func TestCountGame(t *testing.T) {
+	defer persist.SetBackend(persist.SetBackend(persist.NewMemory()))
+	counted := countGame(nil, 100, time.Minute)
+	// Playing on after the game was counted only adds what changed since.
+	counted = countGame(counted, 150, 3*time.Minute)
+	require.Equal(t, &countedGame{Score: 150, Played: 3 * time.Minute}, counted)
+	countGame(nil, 40, time.Minute)
+	require.Equal(t, lifetimeStats{GamesPlayed: 2, TotalScore: 190, TotalDuration: 4 * time.Minute}, load(statsKey))
+}
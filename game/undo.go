@@ -0,0 +1,91 @@
+package game
+
+import (
+	"fmt"
+	"time"
+)
+
+// UndoPolicy controls how many moves can be taken back and what it costs.
+type UndoPolicy int
+
+const (
+	UndoUnlimited UndoPolicy = iota
+	UndoLimited
+	UndoCostsPoints
+)
+
+const (
+	undosPerGame = 3
+	undoCost     = 50
+)
+
+var undoPolicyToName = map[UndoPolicy]string{
+	UndoUnlimited:   "unlimited",
+	UndoLimited:     "limited",
+	UndoCostsPoints: "costly",
+}
+
+var nameToUndoPolicy = map[string]UndoPolicy{
+	"unlimited": UndoUnlimited,
+	"limited":   UndoLimited,
+	"costly":    UndoCostsPoints,
+}
+
+var undoPolicyToLabel = map[UndoPolicy]string{
+	UndoUnlimited:   "Undo: unlimited",
+	UndoLimited:     fmt.Sprintf("Undo: %d per game", undosPerGame),
+	UndoCostsPoints: fmt.Sprintf("Undo: %d points", undoCost),
+}
+
+func loadUndoPolicy() UndoPolicy {
+	return nameToUndoPolicy[load(undoPolicyKey)]
+}
+
+func (g *Game) switchUndoPolicy() {
+	g.undoPolicy = (g.undoPolicy + 1) % UndoPolicy(len(undoPolicyToName))
+	store(undoPolicyKey, undoPolicyToName[g.undoPolicy])
+}
+
+// undo takes back the last move if the undo policy allows it.  The scored attempt at a daily challenge can't be
+// undone, since trying moves again would let it keep the best of many attempts.
+func (g *Game) undo() {
+	if g.daily != "" && !g.practice {
+		g.flash("No undo in the daily")
+		return
+	}
+	var cost int64
+	switch g.undoPolicy {
+	case UndoLimited:
+		if g.session.Undos() >= undosPerGame {
+			g.flash("No undos left")
+			return
+		}
+	case UndoCostsPoints:
+		cost = undoCost
+	}
+	if !g.session.Undo(cost) {
+		return
+	}
+	g.afterHistoryChange()
+}
+
+// redo plays a move taken back by undo again.
+func (g *Game) redo() {
+	if !g.session.Redo() {
+		return
+	}
+	g.afterHistoryChange()
+}
+
+func (g *Game) afterHistoryChange() {
+	g.gameOver = false
+	g.hint = nil
+	g.chosenPieceIdx = -1
+	g.rotations = [numPieceOptions]int{}
+	g.saveGame()
+}
+
+func (g *Game) flash(message string) {
+	g.flashMessage = message
+	g.flashMessageTime = time.Now()
+}
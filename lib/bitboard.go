@@ -0,0 +1,123 @@
+package lib
+
+import "math/bits"
+
+const bitboardWords = 4
+
+// MaxBoardCells is the largest number of cells (width * height) a Bitboard can hold.
+const MaxBoardCells = bitboardWords * 64
+
+// Bitboard is a packed occupancy mask with one bit per cell.  Cells are indexed row-major, so the cell at row r and
+// column c of a board with width w is bit r*w+c.  An 8x8 board fits entirely in the first word.
+type Bitboard [bitboardWords]uint64
+
+func (b Bitboard) Has(i int) bool {
+	return b[i/64]&(1<<uint(i%64)) != 0
+}
+
+func (b *Bitboard) Set(i int) {
+	b[i/64] |= 1 << uint(i%64)
+}
+
+func (b Bitboard) And(o Bitboard) Bitboard {
+	for i := range b {
+		b[i] &= o[i]
+	}
+	return b
+}
+
+func (b Bitboard) Or(o Bitboard) Bitboard {
+	for i := range b {
+		b[i] |= o[i]
+	}
+	return b
+}
+
+func (b Bitboard) AndNot(o Bitboard) Bitboard {
+	for i := range b {
+		b[i] &^= o[i]
+	}
+	return b
+}
+
+func (b Bitboard) Overlaps(o Bitboard) bool {
+	return b[0]&o[0] != 0 || b[1]&o[1] != 0 || b[2]&o[2] != 0 || b[3]&o[3] != 0
+}
+
+func (b Bitboard) Contains(o Bitboard) bool {
+	return b.And(o) == o
+}
+
+func (b Bitboard) IsZero() bool {
+	return b == Bitboard{}
+}
+
+func (b Bitboard) Count() int {
+	var count int
+	for _, word := range b {
+		count += bits.OnesCount64(word)
+	}
+	return count
+}
+
+// ShiftLeft moves every bit n positions towards the higher indexes, which moves a mask n cells further along the
+// board in row-major order.
+func (b Bitboard) ShiftLeft(n int) Bitboard {
+	if n == 0 {
+		return b
+	}
+	var shifted Bitboard
+	wordShift, bitShift := n/64, uint(n%64)
+	for i := len(b) - 1; i >= wordShift; i-- {
+		shifted[i] = b[i-wordShift] << bitShift
+		if bitShift > 0 && i-wordShift > 0 {
+			shifted[i] |= b[i-wordShift-1] >> (64 - bitShift)
+		}
+	}
+	return shifted
+}
+
+// ShiftRight moves every bit n positions towards the lower indexes.
+func (b Bitboard) ShiftRight(n int) Bitboard {
+	if n == 0 {
+		return b
+	}
+	var shifted Bitboard
+	wordShift, bitShift := n/64, uint(n%64)
+	for i := 0; i+wordShift < len(b); i++ {
+		shifted[i] = b[i+wordShift] >> bitShift
+		if bitShift > 0 && i+wordShift+1 < len(b) {
+			shifted[i] |= b[i+wordShift+1] << (64 - bitShift)
+		}
+	}
+	return shifted
+}
+
+// PieceMask is a piece packed into a Bitboard for a board of a particular width, anchored at the top-left cell.
+// Building one costs a pass over the piece shape, so callers testing many locations should build it once and reuse it.
+type PieceMask struct {
+	Bits          Bitboard
+	Width, Height int
+	NumBlocks     int
+}
+
+// NewPieceMask packs the piece for a board of the width.  It returns false if the piece's rows reach past the cells a
+// Bitboard holds, which only happens for pieces taller than any board of the width.
+func NewPieceMask(piece Piece, boardWidth int) (PieceMask, bool) {
+	mask := PieceMask{
+		Width:  piece.Width(),
+		Height: piece.Height(),
+	}
+	if (mask.Height-1)*boardWidth+mask.Width > MaxBoardCells {
+		return PieceMask{}, false
+	}
+	for r := range piece.Shape {
+		for c := range piece.Shape[r] {
+			if piece.Shape[r][c] {
+				mask.Bits.Set(r*boardWidth + c)
+				mask.NumBlocks++
+			}
+		}
+	}
+	return mask, true
+}
@@ -0,0 +1,26 @@
+package lib
+
+import (
+	"fmt"
+	"time"
+)
+
+// DailyDateLayout is the layout of the dates that name daily challenges.
+const DailyDateLayout = "2006-01-02"
+
+// DailyDate returns the date of the daily challenge at t.  Days are in UTC so everyone plays the same challenge at the
+// same time.
+func DailyDate(t time.Time) string {
+	return t.UTC().Format(DailyDateLayout)
+}
+
+// DailyGameID returns the game ID of the daily challenge on the date.  The date is mixed through Xoshiro so the IDs
+// of consecutive days aren't close to each other.
+func DailyGameID(date string) (uint64, error) {
+	day, err := time.Parse(DailyDateLayout, date)
+	if err != nil {
+		return 0, fmt.Errorf("invalid daily date %q: %w", date, err)
+	}
+	dayNumber := uint64(day.Year())*10000 + uint64(day.Month())*100 + uint64(day.Day())
+	return NewXoshiro(dayNumber).Uint64(), nil
+}
@@ -0,0 +1,28 @@
+package lib
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDailyDate(t *testing.T) {
+	// Late in the evening in New York is already the next day in UTC.
+	newYork := time.FixedZone("EDT", -4*60*60)
+	require.Equal(t, "2024-03-02", DailyDate(time.Date(2024, 3, 1, 21, 0, 0, 0, newYork)))
+}
+
+func TestDailyGameID(t *testing.T) {
+	first, err := DailyGameID("2024-03-01")
+	require.NoError(t, err)
+	again, err := DailyGameID("2024-03-01")
+	require.NoError(t, err)
+	require.Equal(t, first, again)
+	next, err := DailyGameID("2024-03-02")
+	require.NoError(t, err)
+	require.NotEqual(t, first, next)
+
+	_, err = DailyGameID("03/01/2024")
+	require.Error(t, err)
+}
@@ -0,0 +1,67 @@
+package lib
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/tyler-smith/go-bip39/wordlists"
+)
+
+// Game IDs are shown and shared as words from the BIP39 English list, which was chosen to be easy to read aloud and to
+// type: no two words share their first four letters.  Each word carries 11 bits, so six of them hold a game ID.
+const (
+	gameIDWords  = 6
+	bitsPerWord  = 11
+	prefixLength = 4
+)
+
+// gameIDWordIndex maps each word, and the first letters of the longer ones, to its index in the list.
+var gameIDWordIndex = func() map[string]uint64 {
+	index := make(map[string]uint64, 2*len(wordlists.English))
+	for i, word := range wordlists.English {
+		index[word] = uint64(i)
+		if len(word) > prefixLength {
+			index[word[:prefixLength]] = uint64(i)
+		}
+	}
+	return index
+}()
+
+// FormatGameID writes the game ID as words separated by dashes, such as "apple-river-stone-gloom-hazard-tilt".
+func FormatGameID(gameID uint64) string {
+	words := make([]string, gameIDWords)
+	for i := gameIDWords - 1; i >= 0; i-- {
+		words[i] = wordlists.English[gameID&(1<<bitsPerWord-1)]
+		gameID >>= bitsPerWord
+	}
+	return strings.Join(words, "-")
+}
+
+// ParseGameID reads a game ID written as words by FormatGameID, or in hex as it was before.  The words can be in any
+// case, separated by dashes, dots or spaces, and cut short once their first four letters are typed.
+func ParseGameID(text string) (uint64, error) {
+	text = strings.ToLower(strings.TrimSpace(text))
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return r == '-' || r == '.' || r == ' '
+	})
+	if len(words) != gameIDWords {
+		return strconv.ParseUint(text, 16, 64)
+	}
+	var gameID uint64
+	for i, word := range words {
+		index, ok := gameIDWordIndex[word]
+		if !ok && len(word) > prefixLength {
+			index, ok = gameIDWordIndex[word[:prefixLength]]
+		}
+		if !ok {
+			return 0, fmt.Errorf("%q is not a game ID word", word)
+		}
+		// The first word only carries the bits that are left over.
+		if i == 0 && index>>(64-bitsPerWord*(gameIDWords-1)) != 0 {
+			return 0, fmt.Errorf("game ID %q is out of range", text)
+		}
+		gameID = gameID<<bitsPerWord | index
+	}
+	return gameID, nil
+}
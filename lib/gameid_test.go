@@ -0,0 +1,36 @@
+package lib
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGameIDRoundTrip(t *testing.T) {
+	for _, gameID := range []uint64{0, 1, 42, 0x5eed, 0x0123456789abcdef, math.MaxUint64} {
+		text := FormatGameID(gameID)
+		parsed, err := ParseGameID(text)
+		require.NoError(t, err, text)
+		require.Equal(t, gameID, parsed, text)
+	}
+	require.Equal(t, "abandon-abandon-abandon-abandon-abandon-aim", FormatGameID(42))
+}
+
+func TestParseGameID(t *testing.T) {
+	gameID, err := ParseGameID("  Abandon abandon.ABAN-aband-abandon-aim ")
+	require.NoError(t, err)
+	require.EqualValues(t, 42, gameID)
+
+	// Hex IDs from links shared before words still work.
+	gameID, err = ParseGameID("5eed")
+	require.NoError(t, err)
+	require.EqualValues(t, 0x5eed, gameID)
+
+	_, err = ParseGameID("abandon-abandon-abandon-abandon-abandon-blocks")
+	require.ErrorContains(t, err, `"blocks" is not a game ID word`)
+	_, err = ParseGameID("zoo-zoo-zoo-zoo-zoo-zoo")
+	require.ErrorContains(t, err, "out of range")
+	_, err = ParseGameID("apple-river")
+	require.Error(t, err)
+}
@@ -0,0 +1,125 @@
+package lib
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// PieceGenerator deals the pieces of a game one at a time.  Generators draw only from the random source they were
+// created with, so a game's pieces are reproducible from its ID.
+type PieceGenerator interface {
+	Next() Piece
+}
+
+// uniformGenerator picks every piece with the same chance.
+type uniformGenerator struct {
+	set        PieceSet
+	randSource rand.Source
+}
+
+func (g *uniformGenerator) Next() Piece {
+	return g.set.RandomRotatedPiece(g.randSource)
+}
+
+// sequenceGenerator deals a fixed list of pieces in order, starting over once it reaches the end.
+type sequenceGenerator struct {
+	pieces []Piece
+	next   int
+}
+
+func (g *sequenceGenerator) Next() Piece {
+	piece := g.pieces[g.next%len(g.pieces)]
+	g.next++
+	return piece
+}
+
+// weightedGenerator picks each piece with a chance proportional to its weight.
+type weightedGenerator struct {
+	set         PieceSet
+	randSource  rand.Source
+	totalWeight int64
+}
+
+func newWeightedGenerator(set PieceSet, randSource rand.Source) *weightedGenerator {
+	g := &weightedGenerator{set: set, randSource: randSource}
+	for _, piece := range set.Pieces {
+		g.totalWeight += int64(max(piece.Weight, 1))
+	}
+	return g
+}
+
+func (g *weightedGenerator) Next() Piece {
+	pick := g.randSource.Int63() % g.totalWeight
+	for _, piece := range g.set.Pieces {
+		pick -= int64(max(piece.Weight, 1))
+		if pick < 0 {
+			return randomRotation(piece, g.randSource)
+		}
+	}
+	panic("weighted pick out of range")
+}
+
+// bagGenerator deals every piece in the set once, in a random order, before starting again with a new order.  This
+// bounds how long a shape can go without being dealt.
+type bagGenerator struct {
+	set        PieceSet
+	randSource rand.Source
+	// bag holds the indexes of the pieces left to deal this round.
+	bag []int
+}
+
+func (g *bagGenerator) Next() Piece {
+	if len(g.bag) == 0 {
+		for i := range g.set.Pieces {
+			g.bag = append(g.bag, i)
+		}
+		for i := len(g.bag) - 1; i > 0; i-- {
+			j := int(g.randSource.Int63() % int64(i+1))
+			g.bag[i], g.bag[j] = g.bag[j], g.bag[i]
+		}
+	}
+	piece := g.set.Pieces[g.bag[len(g.bag)-1]]
+	g.bag = g.bag[:len(g.bag)-1]
+	return randomRotation(piece, g.randSource)
+}
+
+func randomRotation(piece Piece, randSource rand.Source) Piece {
+	rotateTimes := int(randSource.Int63() % 4)
+	for i := 0; i < rotateTimes; i++ {
+		piece = piece.Rotate()
+	}
+	return piece
+}
+
+// PieceDistribution names a piece generator so it can be chosen per game and recorded.
+type PieceDistribution string
+
+const (
+	DistributionUniform  PieceDistribution = ""
+	DistributionWeighted PieceDistribution = "weighted"
+	DistributionBag      PieceDistribution = "bag"
+)
+
+// AllPieceDistributions lists the available distributions, starting with the default.
+var AllPieceDistributions = []PieceDistribution{DistributionUniform, DistributionWeighted, DistributionBag}
+
+func (d PieceDistribution) String() string {
+	if d == DistributionUniform {
+		return "uniform"
+	}
+	return string(d)
+}
+
+// Generator returns a generator that deals pieces from the set using the random source.
+func (d PieceDistribution) Generator(set PieceSet, randSource rand.Source) (PieceGenerator, error) {
+	switch d {
+	case DistributionUniform:
+		return &uniformGenerator{set: set, randSource: randSource}, nil
+	case DistributionWeighted:
+		return newWeightedGenerator(set, randSource), nil
+	case DistributionBag:
+		return &bagGenerator{set: set, randSource: randSource}, nil
+	default:
+		return nil, fmt.Errorf("unknown piece distribution %q", string(d))
+	}
+}
@@ -0,0 +1,77 @@
+package lib
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUniformGeneratorMatchesRandomRotatedPiece(t *testing.T) {
+	generator, err := DistributionUniform.Generator(pieceSets[ClassicPieceSet], rand.NewSource(42))
+	require.NoError(t, err)
+	randSource := rand.NewSource(42)
+	for range 100 {
+		require.Equal(t, RandomRotatedPiece(randSource), generator.Next())
+	}
+}
+
+func TestBagGeneratorDealsEveryPieceOncePerRound(t *testing.T) {
+	set := pieceSets[ClassicPieceSet]
+	generator, err := DistributionBag.Generator(set, rand.NewSource(7))
+	require.NoError(t, err)
+	for range 5 {
+		seen := make(map[string]int)
+		for range set.Pieces {
+			seen[generator.Next().Name]++
+		}
+		require.Len(t, seen, len(set.Pieces))
+	}
+}
+
+func TestWeightedGenerator(t *testing.T) {
+	set, err := ParsePieceSet("test", "> light\n#\n\n> heavy 3\n##")
+	require.NoError(t, err)
+	generator, err := DistributionWeighted.Generator(set, rand.NewSource(1))
+	require.NoError(t, err)
+	counts := make(map[string]int)
+	for range 4000 {
+		counts[generator.Next().Name]++
+	}
+	require.InDelta(t, 3000, counts["heavy"], 150)
+	require.InDelta(t, 1000, counts["light"], 150)
+}
+
+func TestClassicWeightsDealSmallPiecesMoreOften(t *testing.T) {
+	counts := make(map[PieceDistribution]map[string]int)
+	for _, distribution := range []PieceDistribution{DistributionUniform, DistributionWeighted} {
+		generator, err := distribution.Generator(pieceSets[ClassicPieceSet], rand.NewSource(3))
+		require.NoError(t, err)
+		counts[distribution] = make(map[string]int)
+		for range 10000 {
+			counts[distribution][generator.Next().Name]++
+		}
+	}
+	require.Greater(t, counts[DistributionWeighted]["single"], counts[DistributionUniform]["single"]*3/2)
+	require.Less(t, counts[DistributionWeighted]["big-square"], counts[DistributionUniform]["big-square"])
+}
+
+func TestGeneratorsAreDeterministic(t *testing.T) {
+	for _, distribution := range AllPieceDistributions {
+		config := DefaultConfig
+		config.Distribution = distribution
+		s1, err := NewSession(99, config)
+		require.NoError(t, err)
+		s2, err := NewSession(99, config)
+		require.NoError(t, err)
+		require.Equal(t, s1.Tray(), s2.Tray(), distribution.String())
+
+		playGreedily(t, s1)
+		replayed, err := Replay(s1.Record())
+		require.NoError(t, err)
+		require.Equal(t, s1.Tray(), replayed.Tray(), distribution.String())
+	}
+
+	_, err := PieceDistribution("nope").Generator(pieceSets[ClassicPieceSet], rand.NewSource(1))
+	require.Error(t, err)
+}
@@ -0,0 +1,271 @@
+package lib
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// GoalKind is what a level asks the player to do.
+type GoalKind string
+
+const (
+	// GoalClearBoard asks for every block to be cleared off the board.
+	GoalClearBoard GoalKind = "clear"
+	// GoalLines asks for a number of lines to be cleared.
+	GoalLines GoalKind = "lines"
+	// GoalScore asks for a number of points to be scored.
+	GoalScore GoalKind = "score"
+)
+
+// Goal is what the player has to reach to solve a level.  Target is the number of lines or points; it's unused for
+// GoalClearBoard.
+type Goal struct {
+	Kind   GoalKind
+	Target int
+}
+
+func (g Goal) String() string {
+	switch g.Kind {
+	case GoalClearBoard:
+		return "Clear the board"
+	case GoalLines:
+		return fmt.Sprintf("Clear %d lines", g.Target)
+	case GoalScore:
+		return fmt.Sprintf("Score %d", g.Target)
+	default:
+		return string(g.Kind)
+	}
+}
+
+// Met reports whether the game has reached the goal.
+func (g Goal) Met(s *Session) bool {
+	switch g.Kind {
+	case GoalClearBoard:
+		return s.board.Occupancy().IsZero()
+	case GoalLines:
+		return s.lines >= g.Target
+	case GoalScore:
+		return s.Score() >= int64(g.Target)
+	default:
+		return false
+	}
+}
+
+// Level is a hand-made puzzle: a board that starts with blocks on it, a fixed list of pieces dealt in order, and a
+// goal to reach before the pieces run out.
+type Level struct {
+	Name   string
+	Goal   Goal
+	Grid   Grid
+	Pieces []Piece
+	// PlayerRotation lets the player rotate the level's pieces.
+	PlayerRotation bool
+}
+
+// Config returns the rules of a game of the level.
+func (l Level) Config() Config {
+	return Config{
+		Version:        CurrentRulesVersion,
+		Width:          l.Grid.Width(),
+		Height:         l.Grid.Height(),
+		Level:          l.Name,
+		PlayerRotation: l.PlayerRotation,
+	}
+}
+
+//go:embed levels.txt
+var levelFile string
+
+// Levels returns the built-in levels in the order they're meant to be played.  They're parsed on first use, since
+// their pieces come from the classic set, which is only registered once the package is initialized.
+var Levels = sync.OnceValue(func() []Level {
+	levels, err := ParseLevels("levels.txt", levelFile)
+	if err != nil {
+		panic(err)
+	}
+	return levels
+})
+
+// LookupLevel returns the built-in level with the name.
+func LookupLevel(name string) (Level, error) {
+	for _, level := range Levels() {
+		if level.Name == name {
+			return level, nil
+		}
+	}
+	return Level{}, fmt.Errorf("unknown level %q", name)
+}
+
+// ParseLevels parses level definitions.  Each level starts with a line of the form "> name" followed by:
+//
+//	goal clear | goal lines N | goal score N
+//	pieces NAME[:TURNS] ...
+//	rotate
+//
+// and then the rows of its starting board, using "#" for blocks and "." for empty cells.  Pieces come from the
+// classic set and are dealt in the order listed, each turned clockwise TURNS times; "pieces" can be repeated to
+// continue the list.  "rotate" lets the player rotate pieces.  Blank lines and lines starting with "//" are ignored
+// between levels.
+func ParseLevels(name, text string) ([]Level, error) {
+	classic := pieceSets[ClassicPieceSet]
+	var (
+		levels    []Level
+		level     *Level
+		hasGoal   bool
+		startLine int
+	)
+	finishLevel := func() error {
+		if level == nil {
+			return nil
+		}
+		switch {
+		case !hasGoal:
+			return fmt.Errorf("%s:%d: level %q has no goal", name, startLine, level.Name)
+		case len(level.Pieces) == 0:
+			return fmt.Errorf("%s:%d: level %q has no pieces", name, startLine, level.Name)
+		case level.Grid.Height() == 0:
+			return fmt.Errorf("%s:%d: level %q has no board", name, startLine, level.Name)
+		}
+		if err := checkLevelGrid(level.Grid); err != nil {
+			return fmt.Errorf("%s:%d: level %q: %w", name, startLine, level.Name, err)
+		}
+		levels = append(levels, *level)
+		level, hasGoal = nil, false
+		return nil
+	}
+	names := make(map[string]bool)
+	for i, line := range strings.Split(text, "\n") {
+		lineNum := i + 1
+		line = strings.TrimRight(line, " \r")
+		fields := strings.Fields(line)
+		switch {
+		case strings.HasPrefix(line, "//"):
+			continue
+		case strings.HasPrefix(line, ">"):
+			if err := finishLevel(); err != nil {
+				return nil, err
+			}
+			levelName := strings.TrimSpace(line[1:])
+			if levelName == "" || strings.ContainsAny(levelName, " \t") {
+				return nil, fmt.Errorf("%s:%d: expected \"> name\"", name, lineNum)
+			}
+			if names[levelName] {
+				return nil, fmt.Errorf("%s:%d: duplicate level %q", name, lineNum, levelName)
+			}
+			names[levelName] = true
+			level = &Level{Name: levelName}
+			startLine = lineNum
+		case line == "":
+			if err := finishLevel(); err != nil {
+				return nil, err
+			}
+		case level == nil:
+			return nil, fmt.Errorf("%s:%d: line outside of a level", name, lineNum)
+		case len(fields) == 0:
+			return nil, fmt.Errorf("%s:%d: blank lines can't contain whitespace", name, lineNum)
+		case fields[0] == "goal":
+			goal, err := parseGoal(fields[1:])
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", name, lineNum, err)
+			}
+			level.Goal = goal
+			hasGoal = true
+		case fields[0] == "pieces":
+			for _, field := range fields[1:] {
+				piece, err := parseLevelPiece(classic, field)
+				if err != nil {
+					return nil, fmt.Errorf("%s:%d: %w", name, lineNum, err)
+				}
+				level.Pieces = append(level.Pieces, piece)
+			}
+		case line == "rotate":
+			level.PlayerRotation = true
+		default:
+			row := make([]CellState, len(line))
+			for c, cell := range line {
+				switch cell {
+				case '#':
+					row[c] = Occupied
+				case '.':
+				default:
+					return nil, fmt.Errorf("%s:%d: unexpected %q, use \"#\" for blocks and \".\" for empty cells",
+						name, lineNum, cell)
+				}
+			}
+			if level.Grid.Height() > 0 && len(row) != level.Grid.Width() {
+				return nil, fmt.Errorf("%s:%d: row is %d cells wide, want %d", name, lineNum, len(row),
+					level.Grid.Width())
+			}
+			level.Grid = append(level.Grid, row)
+		}
+	}
+	if err := finishLevel(); err != nil {
+		return nil, err
+	}
+	return levels, nil
+}
+
+func parseGoal(fields []string) (Goal, error) {
+	if len(fields) == 1 && fields[0] == string(GoalClearBoard) {
+		return Goal{Kind: GoalClearBoard}, nil
+	}
+	if len(fields) == 2 && (fields[0] == string(GoalLines) || fields[0] == string(GoalScore)) {
+		target, err := strconv.Atoi(fields[1])
+		if err != nil || target <= 0 {
+			return Goal{}, fmt.Errorf("goal target %q must be a positive integer", fields[1])
+		}
+		return Goal{Kind: GoalKind(fields[0]), Target: target}, nil
+	}
+	return Goal{}, errors.New("expected \"goal clear\", \"goal lines N\" or \"goal score N\"")
+}
+
+// parseLevelPiece returns the piece named by NAME[:TURNS] from the set.
+func parseLevelPiece(set PieceSet, field string) (Piece, error) {
+	pieceName, turnsText, hasTurns := strings.Cut(field, ":")
+	var turns int
+	if hasTurns {
+		var err error
+		turns, err = strconv.Atoi(turnsText)
+		if err != nil || turns < 0 || turns > 3 {
+			return Piece{}, fmt.Errorf("piece %q: turns must be 0 to 3", field)
+		}
+	}
+	for _, piece := range set.Pieces {
+		if piece.Name == pieceName {
+			for range turns {
+				piece = piece.Rotate()
+			}
+			return piece, nil
+		}
+	}
+	return Piece{}, fmt.Errorf("unknown piece %q", pieceName)
+}
+
+// checkLevelGrid checks that the grid fits a board and has no full lines, which would be cleared by whatever piece
+// happened to be placed across them first.
+func checkLevelGrid(grid Grid) error {
+	width, height := grid.Width(), grid.Height()
+	if width*height > MaxBoardCells {
+		return fmt.Errorf("board %dx%d is too large", width, height)
+	}
+	for r := range height {
+		if !slices.Contains(grid[r], Empty) {
+			return fmt.Errorf("row %d is full", r+1)
+		}
+	}
+	for c := range width {
+		full := true
+		for r := range height {
+			full = full && grid[r][c] == Occupied
+		}
+		if full {
+			return fmt.Errorf("column %d is full", c+1)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package lib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLevels(t *testing.T) {
+	levels, err := ParseLevels("test", `// a comment
+> corner
+goal lines 1
+pieces single corner:2
+rotate
+#.
+..
+`)
+	require.NoError(t, err)
+	require.Len(t, levels, 1)
+	level := levels[0]
+	require.Equal(t, "corner", level.Name)
+	require.Equal(t, Goal{Kind: GoalLines, Target: 1}, level.Goal)
+	require.Equal(t, Grid{{Occupied, Empty}, {Empty, Empty}}, level.Grid)
+	require.True(t, level.PlayerRotation)
+	require.Len(t, level.Pieces, 2)
+	require.Equal(t, "#.\n##", level.Pieces[1].String())
+
+	for text, message := range map[string]string{
+		"goal clear":                             "test:1: line outside of a level",
+		"> a\npieces single\n..":                 `test:1: level "a" has no goal`,
+		"> a\ngoal clear\n..":                    `test:1: level "a" has no pieces`,
+		"> a\ngoal lines 0":                      `test:2: goal target "0" must be a positive integer`,
+		"> a\ngoal clear\npieces blob":           `test:3: unknown piece "blob"`,
+		"> a\ngoal clear\npieces single:4":       `test:3: piece "single:4": turns must be 0 to 3`,
+		"> a\ngoal clear\npieces single\n..\n.":  "test:5: row is 1 cells wide, want 2",
+		"> a\ngoal clear\npieces single\n##\n..": `test:1: level "a": row 1 is full`,
+		"> a\ngoal clear\npieces single\n#.\n#.": `test:1: level "a": column 1 is full`,
+		"> a\ngoal clear\n\t\npieces single":     "test:3: blank lines can't contain whitespace",
+	} {
+		_, err := ParseLevels("test", text)
+		require.EqualError(t, err, message, text)
+	}
+}
+
+// solveLevel searches the moves of the level being played for a way to solve it, leaving the session solved if there's
+// one.
+func solveLevel(s *Session) bool {
+	if s.Solved() {
+		return true
+	}
+	for _, move := range s.LegalMoves() {
+		if _, err := s.Play(move.Move); err != nil {
+			panic(err)
+		}
+		if solveLevel(s) {
+			return true
+		}
+		s.Undo(0)
+	}
+	return false
+}
+
+func TestBuiltInLevels(t *testing.T) {
+	require.NotEmpty(t, Levels())
+	for _, level := range Levels() {
+		s, err := NewSession(0, level.Config())
+		require.NoError(t, err, level.Name)
+		require.Equal(t, len(level.Pieces), s.PiecesLeft(), level.Name)
+		require.False(t, s.GameOver(), level.Name)
+
+		require.True(t, solveLevel(s), level.Name)
+	}
+}
+
+func TestPlayLevel(t *testing.T) {
+	level, err := LookupLevel("first-steps")
+	require.NoError(t, err)
+	s, err := NewSession(0, level.Config())
+	require.NoError(t, err)
+	require.Equal(t, 3, s.Board().Occupancy().Count())
+	require.Nil(t, s.Tray()[2])
+
+	_, err = s.Play(Move{Slot: 0, Loc: Location{C: 1, R: 7}})
+	require.NoError(t, err)
+	require.False(t, s.Solved())
+	require.Equal(t, 1, s.PiecesLeft())
+	_, err = s.Play(Move{Slot: 1, Loc: Location{C: 5, R: 7}})
+	require.NoError(t, err)
+	require.True(t, s.Solved())
+	require.True(t, s.GameOver())
+	require.Equal(t, 1, s.Lines())
+	require.Zero(t, s.PiecesLeft())
+
+	replayed, err := Replay(s.Record())
+	require.NoError(t, err)
+	require.True(t, replayed.Solved())
+}
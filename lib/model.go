@@ -1,25 +1,52 @@
 package lib
 
 import (
+	"fmt"
+	"maps"
 	"strings"
 )
 
+// CellState is the occupancy of a single board cell.
 type CellState int
 
 const (
 	Empty CellState = iota
-	Pending
-	Invalid
-	FullLine
 	Occupied
-	Unchosen
-	Hovering
-	CantMove
 )
 
-const BoardSize = 8
+const DefaultBoardSize = 8
 
-type Grid [BoardSize][BoardSize]CellState
+// BoxSize is the width and height of the boxes cleared in games with Boxes, as in sudoku.
+const BoxSize = 3
+
+type Grid [][]CellState
+
+func NewGrid(width, height int) Grid {
+	grid := make(Grid, height)
+	for r := range grid {
+		grid[r] = make([]CellState, width)
+	}
+	return grid
+}
+
+func (g Grid) Width() int {
+	if len(g) == 0 {
+		return 0
+	}
+	return len(g[0])
+}
+
+func (g Grid) Height() int {
+	return len(g)
+}
+
+func (g Grid) Clone() Grid {
+	clone := make(Grid, len(g))
+	for r := range g {
+		clone[r] = append([]CellState(nil), g[r]...)
+	}
+	return clone
+}
 
 func (g Grid) Empty() bool {
 	for r := range g {
@@ -35,12 +62,7 @@ func (g Grid) Empty() bool {
 func (g Grid) String() string {
 	cellStateToIcon := map[CellState]string{
 		Empty:    "e",
-		Pending:  "p",
-		Invalid:  "i",
-		FullLine: "f",
 		Occupied: "o",
-		Unchosen: "u",
-		Hovering: "h",
 	}
 	var sb strings.Builder
 	for _, row := range g {
@@ -53,11 +75,44 @@ func (g Grid) String() string {
 }
 
 type Board struct {
-	gridHistory *Stack[Grid]
+	width, height int
+	history       *Stack[Bitboard]
+
+	// Precomputed masks covering each full row and column, and the whole board.
+	rowMasks []Bitboard
+	colMasks []Bitboard
+	fullMask Bitboard
+	// boxMasks cover each box in row-major order on boards that clear boxes, and are nil otherwise.
+	boxMasks []Bitboard
+	// pieceMasks caches the mask of each piece shape placed or tested on the board.  Even methods that only read the
+	// board add to it, so a Board isn't safe for concurrent use; each clone gets its own copy so clones can be used
+	// from different goroutines.
+	pieceMasks map[shapeKey]PieceMask
 }
 
+// shapeKey identifies a piece shape of up to 64 cells, with bit r*width+c set for each block.
+type shapeKey struct {
+	width, height int
+	blocks        uint64
+}
+
+// Color identifies the color of a piece and of the blocks it places.  Pieces are given colors 1 to NumPieceColors by
+// their position in their piece set.  NoColor is for blocks that didn't come from a set, such as those placed by
+// CheatPiece or that a level starts with.
+type Color uint8
+
+const (
+	NoColor        Color = 0
+	NumPieceColors       = 8
+)
+
 type Piece struct {
 	Shape [][]bool
+	// Name, Weight and Color come from the piece set the piece was defined in.  Weight is the piece's relative chance
+	// of being dealt.
+	Name   string
+	Weight int
+	Color  Color
 }
 
 func (p Piece) Height() int {
@@ -65,12 +120,18 @@ func (p Piece) Height() int {
 }
 
 func (p Piece) Width() int {
+	if len(p.Shape) == 0 {
+		return 0
+	}
 	return len(p.Shape[0])
 }
 
 func (p Piece) Rotate() Piece {
 	rotated := Piece{
-		Shape: make([][]bool, len(p.Shape[0])),
+		Shape:  make([][]bool, len(p.Shape[0])),
+		Name:   p.Name,
+		Weight: p.Weight,
+		Color:  p.Color,
 	}
 	for c := range p.Shape[0] {
 		rotated.Shape[c] = make([]bool, len(p.Shape))
@@ -95,16 +156,186 @@ func (p Piece) NumBlocks() int {
 	return numBlocks
 }
 
-func NewBoard() Board {
-	gridHistory := NewStack[Grid]()
-	gridHistory.Push(Grid{})
+// Flip mirrors the piece left to right.
+func (p Piece) Flip() Piece {
+	flipped := Piece{
+		Shape:  make([][]bool, len(p.Shape)),
+		Name:   p.Name,
+		Weight: p.Weight,
+		Color:  p.Color,
+	}
+	for r := range p.Shape {
+		flipped.Shape[r] = make([]bool, len(p.Shape[r]))
+		for c := range p.Shape[r] {
+			flipped.Shape[r][len(p.Shape[r])-1-c] = p.Shape[r][c]
+		}
+	}
+	return flipped
+}
+
+// Equal reports whether the pieces have the same shape in the same orientation, ignoring their names and weights.
+func (p Piece) Equal(o Piece) bool {
+	return p.String() == o.String()
+}
+
+// String draws the shape with "#" for blocks and "." for gaps, one line per row.
+func (p Piece) String() string {
+	var sb strings.Builder
+	for r, row := range p.Shape {
+		if r > 0 {
+			sb.WriteString("\n")
+		}
+		for _, block := range row {
+			if block {
+				sb.WriteString("#")
+			} else {
+				sb.WriteString(".")
+			}
+		}
+	}
+	return sb.String()
+}
+
+// Rotations returns the distinct quarter turns of the piece, starting with the piece itself.  A square has one, a bar
+// two and most other shapes four.
+func (p Piece) Rotations() []Piece {
+	var rotations []Piece
+	for range 4 {
+		if !containsShape(rotations, p) {
+			rotations = append(rotations, p)
+		}
+		p = p.Rotate()
+	}
+	return rotations
+}
+
+// Orientations returns the distinct rotations of the piece followed by those of its mirror image that aren't
+// rotations of the piece.
+func (p Piece) Orientations() []Piece {
+	orientations := p.Rotations()
+	for _, flipped := range p.Flip().Rotations() {
+		if !containsShape(orientations, flipped) {
+			orientations = append(orientations, flipped)
+		}
+	}
+	return orientations
+}
+
+// Chiral reports whether the piece's mirror image can't be reached by rotating it.
+func (p Piece) Chiral() bool {
+	return !containsShape(p.Rotations(), p.Flip())
+}
+
+// Normalize returns the orientation of the piece that sorts first by String, so pieces that are rotations or
+// reflections of each other normalize to the same shape.
+func (p Piece) Normalize() Piece {
+	normalized := p
+	for _, orientation := range p.Orientations() {
+		if orientation.String() < normalized.String() {
+			normalized = orientation
+		}
+	}
+	return normalized
+}
+
+func containsShape(pieces []Piece, piece Piece) bool {
+	for _, p := range pieces {
+		if p.Equal(piece) {
+			return true
+		}
+	}
+	return false
+}
+
+func NewBoard(width, height int) Board {
+	if width <= 0 || height <= 0 || width*height > MaxBoardCells {
+		panic(fmt.Sprintf("unsupported board size %dx%d", width, height))
+	}
+	history := NewStack[Bitboard]()
+	history.Push(Bitboard{})
+	rowMasks := make([]Bitboard, height)
+	colMasks := make([]Bitboard, width)
+	for r := range height {
+		for c := range width {
+			rowMasks[r].Set(r*width + c)
+			colMasks[c].Set(r*width + c)
+		}
+	}
+	var fullMask Bitboard
+	for _, rowMask := range rowMasks {
+		fullMask = fullMask.Or(rowMask)
+	}
 	return Board{
-		gridHistory: gridHistory,
+		width:      width,
+		height:     height,
+		history:    history,
+		rowMasks:   rowMasks,
+		colMasks:   colMasks,
+		fullMask:   fullMask,
+		pieceMasks: make(map[shapeKey]PieceMask),
+	}
+}
+
+// NewBoardFromGrid returns a board of the grid's size with its occupied cells filled.
+func NewBoardFromGrid(grid Grid) Board {
+	b := NewBoard(grid.Width(), grid.Height())
+	var occupancy Bitboard
+	for r := range grid {
+		for c := range grid[r] {
+			if grid[r][c] == Occupied {
+				occupancy.Set(r*b.width + c)
+			}
+		}
+	}
+	b.history = NewStack[Bitboard]()
+	b.history.Push(occupancy)
+	return b
+}
+
+// setBoxes makes the board clear full boxes as well as full lines.  Its sides must be multiples of BoxSize.
+func (b *Board) setBoxes() {
+	boxesPerRow := b.width / BoxSize
+	b.boxMasks = make([]Bitboard, boxesPerRow*(b.height/BoxSize))
+	for r := range b.height {
+		for c := range b.width {
+			b.boxMasks[(r/BoxSize)*boxesPerRow+c/BoxSize].Set(r*b.width + c)
+		}
+	}
+}
+
+// BoxCells returns the cells of the box with the index, counting boxes in row-major order.
+func (b *Board) BoxCells(box int) []Location {
+	boxesPerRow := b.width / BoxSize
+	top, left := (box/boxesPerRow)*BoxSize, (box%boxesPerRow)*BoxSize
+	cells := make([]Location, 0, BoxSize*BoxSize)
+	for r := top; r < top+BoxSize; r++ {
+		for c := left; c < left+BoxSize; c++ {
+			cells = append(cells, Location{C: c, R: r})
+		}
 	}
+	return cells
+}
+
+// Clone returns a board with the same size and occupancy as b but its own history.
+func (b *Board) Clone() Board {
+	clone := *b
+	clone.pieceMasks = maps.Clone(b.pieceMasks)
+	clone.history = NewStack[Bitboard]()
+	clone.history.Push(b.Occupancy())
+	return clone
+}
+
+func (b *Board) Width() int {
+	return b.width
+}
+
+func (b *Board) Height() int {
+	return b.height
 }
 
 type Location struct {
-	C, R int
+	C int `json:"c"`
+	R int `json:"r"`
 }
 
 type PieceLocation struct {
@@ -113,155 +344,191 @@ type PieceLocation struct {
 }
 
 func (b *Board) Clear() {
-	b.gridHistory = NewStack[Grid]()
+	b.history = NewStack[Bitboard]()
 }
 
+// CanPlacePiece reports whether the piece has any legal placement, stopping at the first one found.
 func (b *Board) CanPlacePiece(piece Piece) bool {
+	for range b.placements(b.PieceMask(piece), piece) {
+		return true
+	}
+	return false
+}
+
+// PieceMask packs the piece into a mask for this board's width.  Masks are cached by shape, so each rotation of a
+// piece is only packed once.  A piece too big to pack is taller than the board, so it gets a mask with its size but no
+// blocks, which fits nowhere.
+func (b *Board) PieceMask(piece Piece) PieceMask {
+	key := shapeKey{width: piece.Width(), height: piece.Height()}
+	if key.width*key.height > 64 {
+		return newBoardPieceMask(piece, b.width)
+	}
 	for r := range piece.Shape {
 		for c := range piece.Shape[r] {
-			loc := Location{C: c, R: r}
-			if b.ValidatePiece(PieceLocation{Piece: piece, Loc: loc}, false) {
-				return true
+			if piece.Shape[r][c] {
+				key.blocks |= 1 << uint(r*key.width+c)
 			}
 		}
 	}
-	return false
+	mask, ok := b.pieceMasks[key]
+	if !ok {
+		mask = newBoardPieceMask(piece, b.width)
+		b.pieceMasks[key] = mask
+	}
+	return mask
 }
 
-func (b *Board) ValidatePiece(
-	pieceLoc PieceLocation,
-	allowPieceOverlap bool,
-) bool {
-	grid, ok := b.gridHistory.Peek()
+func newBoardPieceMask(piece Piece, boardWidth int) PieceMask {
+	mask, ok := NewPieceMask(piece, boardWidth)
 	if !ok {
-		panic("no grid history")
+		return PieceMask{Width: piece.Width(), Height: piece.Height()}
 	}
-	loc := pieceLoc.Loc
-	piece := pieceLoc.Piece
-	if loc.C < 0 || loc.R < 0 {
+	return mask
+}
+
+func (b *Board) inBounds(mask PieceMask, loc Location) bool {
+	return loc.C >= 0 && loc.R >= 0 && loc.C+mask.Width <= b.width && loc.R+mask.Height <= b.height
+}
+
+// Fits reports whether the masked piece lies within the board at loc without overlapping any occupied cell.
+func (b *Board) Fits(mask PieceMask, loc Location) bool {
+	if !b.inBounds(mask, loc) {
 		return false
 	}
-	if loc.C+piece.Width() > BoardSize || loc.R+piece.Height() > BoardSize {
-		return false
+	return !b.Occupancy().Overlaps(mask.Bits.ShiftLeft(loc.R*b.width + loc.C))
+}
+
+// fullLines returns the rows, columns and, on boards that clear them, boxes that are completely filled in occupancy
+// once the masked piece is placed at loc, along with a mask of their cells.  Full lines are cleared as soon as they're
+// filled, so only the lines the piece crosses are checked.
+func (b *Board) fullLines(occupancy Bitboard, mask PieceMask, loc Location) ([]int, []int, []int, Bitboard) {
+	var clearedRows, clearedCols, clearedBoxes []int
+	var cleared Bitboard
+	for r := loc.R; r < loc.R+mask.Height; r++ {
+		if occupancy.Contains(b.rowMasks[r]) {
+			clearedRows = append(clearedRows, r)
+			cleared = cleared.Or(b.rowMasks[r])
+		}
 	}
-	if allowPieceOverlap {
-		return true
+	for c := loc.C; c < loc.C+mask.Width; c++ {
+		if occupancy.Contains(b.colMasks[c]) {
+			clearedCols = append(clearedCols, c)
+			cleared = cleared.Or(b.colMasks[c])
+		}
 	}
-	for r := range piece.Shape {
-		for c := range piece.Shape[r] {
-			if piece.Shape[r][c] {
-				if grid[loc.R+r][loc.C+c] == Occupied {
-					return false
+	if b.boxMasks != nil {
+		boxesPerRow := b.width / BoxSize
+		for boxR := loc.R / BoxSize; boxR <= (loc.R+mask.Height-1)/BoxSize; boxR++ {
+			for boxC := loc.C / BoxSize; boxC <= (loc.C+mask.Width-1)/BoxSize; boxC++ {
+				box := boxR*boxesPerRow + boxC
+				if occupancy.Contains(b.boxMasks[box]) {
+					clearedBoxes = append(clearedBoxes, box)
+					cleared = cleared.Or(b.boxMasks[box])
 				}
 			}
 		}
 	}
-	return true
+	return clearedRows, clearedCols, clearedBoxes, cleared
 }
 
-func (b *Board) AddPiece(
-	pieceLoc PieceLocation,
-	pending bool,
-) (Grid, []int, []int, bool) {
-	if !b.ValidatePiece(pieceLoc, pending) {
-		return b.GetGrid(), nil, nil, false
-	}
-	grid, ok := b.gridHistory.Peek()
+// Place commits the masked piece at loc if it fits, clearing any lines it completes.  It returns the cleared rows and
+// columns and whether the piece was placed.  Cleared boxes are only reported by placements.
+func (b *Board) Place(mask PieceMask, loc Location) ([]int, []int, bool) {
+	placement, ok := b.placement(mask, PieceLocation{Loc: loc})
 	if !ok {
-		panic("no grid history")
+		return nil, nil, false
 	}
-	piece := pieceLoc.Piece
-	loc := pieceLoc.Loc
-	anyInvalid := false
+	b.Apply(placement)
+	return placement.ClearedRows, placement.ClearedCols, true
+}
 
-	newPieceState := Occupied
-	newFullLineState := Empty
-	if pending {
-		newPieceState = Pending
-		newFullLineState = FullLine
-	}
+func (b *Board) ValidatePiece(pieceLoc PieceLocation) bool {
+	return b.Fits(b.PieceMask(pieceLoc.Piece), pieceLoc.Loc)
+}
 
-	// Visualize the piece on the board
-	for r := range piece.Shape {
-		for c := range piece.Shape[r] {
-			if !piece.Shape[r][c] {
-				continue
-			}
-			if grid[loc.R+r][loc.C+c] == Occupied {
-				grid[loc.R+r][loc.C+c] = Invalid
-				anyInvalid = true
-				continue
-			}
-			grid[loc.R+r][loc.C+c] = newPieceState
-		}
-	}
-	if anyInvalid {
-		return grid, nil, nil, false
-	}
+// AddPiece places the piece if it fits, clearing any lines it completes.  It returns the cleared rows and columns and
+// whether the piece was placed.
+func (b *Board) AddPiece(pieceLoc PieceLocation) ([]int, []int, bool) {
+	return b.Place(b.PieceMask(pieceLoc.Piece), pieceLoc.Loc)
+}
 
-	clearedRows := make([]int, 0)
-	clearedCols := make([]int, 0)
-	cellsToUpdate := make([]Location, 0)
-	// Find cleared rows
-	for r := range BoardSize {
-		full := true
-		for c := range BoardSize {
-			if grid[r][c] != Occupied && grid[r][c] != Pending {
-				full = false
-				break
-			}
-		}
-		if !full {
-			continue
-		}
-		clearedRows = append(clearedRows, r)
-		for c := range BoardSize {
-			if grid[r][c] != Pending {
-				cellsToUpdate = append(cellsToUpdate, Location{C: c, R: r})
-			}
-		}
+// Preview describes what placing a piece would do to the board.
+type Preview struct {
+	// Overlapping are the piece cells that collide with occupied cells.
+	Overlapping []Location
+	// Filled are the piece cells that would become occupied.
+	Filled []Location
+	// ClearedRows, ClearedCols and ClearedBoxes are the lines and boxes the placement would complete.  They are only
+	// set for valid placements.
+	ClearedRows  []int
+	ClearedCols  []int
+	ClearedBoxes []int
+}
+
+func (p Preview) Valid() bool {
+	return len(p.Overlapping) == 0
+}
+
+// Preview reports the effect of placing the piece without changing the board.  It returns false if the piece doesn't
+// lie within the board.
+func (b *Board) Preview(pieceLoc PieceLocation) (Preview, bool) {
+	mask := b.PieceMask(pieceLoc.Piece)
+	loc := pieceLoc.Loc
+	if !b.inBounds(mask, loc) {
+		return Preview{}, false
 	}
-	// Find cleared columns
-	for c := range BoardSize {
-		full := true
-		for r := range BoardSize {
-			if grid[r][c] != Occupied && grid[r][c] != Pending {
-				full = false
-				break
+	occupancy := b.Occupancy()
+	var preview Preview
+	for r := range mask.Height {
+		for c := range mask.Width {
+			if !mask.Bits.Has(r*b.width + c) {
+				continue
 			}
-		}
-		if !full {
-			continue
-		}
-		clearedCols = append(clearedCols, c)
-		for r := range BoardSize {
-			if grid[r][c] != Pending {
-				cellsToUpdate = append(cellsToUpdate, Location{C: c, R: r})
+			cellLoc := Location{C: loc.C + c, R: loc.R + r}
+			if occupancy.Has(cellLoc.R*b.width + cellLoc.C) {
+				preview.Overlapping = append(preview.Overlapping, cellLoc)
+			} else {
+				preview.Filled = append(preview.Filled, cellLoc)
 			}
 		}
 	}
-	// Clear full lines
-	for _, cellLoc := range cellsToUpdate {
-		grid[cellLoc.R][cellLoc.C] = newFullLineState
-	}
-	if !pending {
-		b.gridHistory.Push(grid)
+	if preview.Valid() {
+		placed := occupancy.Or(mask.Bits.ShiftLeft(loc.R*b.width + loc.C))
+		preview.ClearedRows, preview.ClearedCols, preview.ClearedBoxes, _ = b.fullLines(placed, mask, loc)
 	}
-	return grid, clearedRows, clearedCols, true
+	return preview, true
 }
 
 func (b *Board) Undo() bool {
-	if b.gridHistory.Len() == 1 {
+	if b.history.Len() == 1 {
 		return false
 	}
-	b.gridHistory.Pop()
+	b.history.Pop()
 	return true
 }
 
-func (b *Board) GetGrid() Grid {
-	grid, ok := b.gridHistory.Peek()
+// Occupancy returns the packed occupancy of the current board.
+func (b *Board) Occupancy() Bitboard {
+	occupancy, ok := b.history.Peek()
 	if !ok {
 		panic("no grid history")
 	}
+	return occupancy
+}
+
+func (b *Board) GetGrid() Grid {
+	return b.OccupancyGrid(b.Occupancy())
+}
+
+// OccupancyGrid unpacks an occupancy mask for this board, such as a placement result, into a Grid.
+func (b *Board) OccupancyGrid(occupancy Bitboard) Grid {
+	grid := NewGrid(b.width, b.height)
+	for r := range grid {
+		for c := range grid[r] {
+			if occupancy.Has(r*b.width + c) {
+				grid[r][c] = Occupied
+			}
+		}
+	}
 	return grid
 }
@@ -0,0 +1,167 @@
+package lib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRectangularBoardClearsLines(t *testing.T) {
+	board := NewBoard(3, 5)
+	require.False(t, board.ValidatePiece(PieceLocation{Piece: parsePiece("####"), Loc: Location{}}))
+
+	clearedRows, clearedCols, ok := board.AddPiece(PieceLocation{Piece: parsePiece("#\n#\n#\n#"), Loc: Location{C: 2, R: 1}})
+	require.True(t, ok)
+	require.Empty(t, clearedRows)
+	require.Empty(t, clearedCols)
+
+	clearedRows, clearedCols, ok = board.AddPiece(PieceLocation{Piece: parsePiece("##"), Loc: Location{C: 0, R: 4}})
+	require.True(t, ok)
+	grid := board.GetGrid()
+	require.Equal(t, []int{4}, clearedRows)
+	require.Empty(t, clearedCols)
+	require.Equal(t, 3, grid.Width())
+	require.Equal(t, 5, grid.Height())
+	require.Equal(t, Occupied, grid[1][2])
+	require.Equal(t, Empty, grid[4][2])
+}
+
+func TestBitboardShiftAcrossWords(t *testing.T) {
+	var b Bitboard
+	b.Set(0)
+	b.Set(63)
+	shifted := b.ShiftLeft(70)
+	require.True(t, shifted.Has(70))
+	require.True(t, shifted.Has(133))
+	require.Equal(t, 2, shifted.Count())
+}
+
+func TestWideBoardClearsColumnAcrossWords(t *testing.T) {
+	board := NewBoard(10, 10)
+	column := parsePiece("#\n#\n#\n#\n#")
+	_, _, ok := board.AddPiece(PieceLocation{Piece: column, Loc: Location{C: 7, R: 0}})
+	require.True(t, ok)
+	clearedRows, clearedCols, ok := board.AddPiece(PieceLocation{Piece: column, Loc: Location{C: 7, R: 5}})
+	require.True(t, ok)
+	require.Empty(t, clearedRows)
+	require.Equal(t, []int{7}, clearedCols)
+	require.True(t, board.Occupancy().IsZero())
+}
+
+func TestPieceMasksAreCachedByShape(t *testing.T) {
+	board := NewBoard(10, 10)
+	var rotations int
+	for _, piece := range AllPieces {
+		for _, rotation := range piece.Rotations() {
+			mask, ok := NewPieceMask(rotation, board.Width())
+			require.True(t, ok)
+			require.Equal(t, mask, board.PieceMask(rotation))
+			rotations++
+		}
+	}
+	require.LessOrEqual(t, len(board.pieceMasks), rotations)
+
+	// Pieces with the same shape share a mask, even when they're separate copies.
+	cached := len(board.pieceMasks)
+	for _, piece := range AllPieces {
+		board.PieceMask(piece.Rotate().Rotate().Rotate().Rotate())
+		board.CanPlacePiece(piece)
+	}
+	require.Len(t, board.pieceMasks, cached)
+
+	// Clones have their own cache.
+	clone := board.Clone()
+	clone.PieceMask(parsePiece("######"))
+	require.Len(t, board.pieceMasks, cached)
+	require.Len(t, clone.pieceMasks, cached+1)
+}
+
+func TestPieceTallerThanWideBoard(t *testing.T) {
+	// A column of three reaches past the cells a Bitboard holds on a board this wide.
+	board := NewBoard(MaxBoardCells/2, 2)
+	column := parsePiece("#\n#\n#")
+	_, ok := NewPieceMask(column, board.Width())
+	require.False(t, ok)
+	require.False(t, board.CanPlacePiece(column))
+	require.False(t, board.ValidatePiece(PieceLocation{Piece: column}))
+	// Only the column turned on its side fits.
+	require.Equal(t, 1, board.Mobility([]Piece{column}))
+	_, ok = board.Preview(PieceLocation{Piece: column})
+	require.False(t, ok)
+}
+
+func BenchmarkFits(b *testing.B) {
+	board := NewBoard(DefaultBoardSize, DefaultBoardSize)
+	masks := make([]PieceMask, len(AllPieces))
+	for i, piece := range AllPieces {
+		masks[i] = board.PieceMask(piece)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, mask := range masks {
+			for r := range DefaultBoardSize {
+				for c := range DefaultBoardSize {
+					board.Fits(mask, Location{C: c, R: r})
+				}
+			}
+		}
+	}
+}
+
+func TestPreviewDoesNotMutateBoard(t *testing.T) {
+	board := NewBoard(4, 4)
+	_, _, ok := board.AddPiece(PieceLocation{Piece: parsePiece("###"), Loc: Location{C: 0, R: 0}})
+	require.True(t, ok)
+	before := board.Occupancy()
+
+	preview, ok := board.Preview(PieceLocation{Piece: parsePiece("##"), Loc: Location{C: 2, R: 0}})
+	require.True(t, ok)
+	require.False(t, preview.Valid())
+	require.Equal(t, []Location{{C: 2, R: 0}}, preview.Overlapping)
+	require.Equal(t, []Location{{C: 3, R: 0}}, preview.Filled)
+	require.Empty(t, preview.ClearedRows)
+
+	preview, ok = board.Preview(PieceLocation{Piece: parsePiece("#"), Loc: Location{C: 3, R: 0}})
+	require.True(t, ok)
+	require.True(t, preview.Valid())
+	require.Equal(t, []int{0}, preview.ClearedRows)
+	require.Empty(t, preview.ClearedCols)
+
+	_, ok = board.Preview(PieceLocation{Piece: parsePiece("##"), Loc: Location{C: 3, R: 0}})
+	require.False(t, ok)
+	require.Equal(t, before, board.Occupancy())
+}
+
+func TestLegalPlacements(t *testing.T) {
+	board := NewBoard(3, 3)
+	_, _, ok := board.AddPiece(PieceLocation{Piece: parsePiece("##\n##"), Loc: Location{C: 0, R: 0}})
+	require.True(t, ok)
+
+	placements := board.LegalPlacements(parsePiece("#\n#"))
+	locs := make([]Location, 0, len(placements))
+	for _, placement := range placements {
+		locs = append(locs, placement.Loc)
+	}
+	require.Equal(t, []Location{{C: 2, R: 0}, {C: 2, R: 1}}, locs)
+	require.Equal(t, []int{1}, placements[1].ClearedRows)
+	require.Empty(t, placements[1].ClearedCols)
+	require.Equal(t, 2+10, placements[1].Points)
+	require.Equal(t, Grid{
+		{Occupied, Occupied, Empty},
+		{Empty, Empty, Empty},
+		{Empty, Empty, Occupied},
+	}, placements[1].Grid())
+
+	require.True(t, board.CanPlacePiece(parsePiece("###")))
+	require.False(t, board.CanPlacePiece(parsePiece("##\n##")))
+
+	placements = board.LegalPlacements(parsePiece("###"))
+	require.Len(t, placements, 1)
+	require.Equal(t, Location{C: 0, R: 2}, placements[0].Loc)
+	require.Equal(t, []int{0, 1}, placements[0].ClearedCols)
+	require.Equal(t, []int{2}, placements[0].ClearedRows)
+	require.Equal(t, 3+3*10+300, placements[0].Points)
+
+	board.Apply(placements[0])
+	require.True(t, board.GetGrid().Empty())
+}
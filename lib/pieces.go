@@ -1,63 +1,257 @@
 package lib
 
 import (
-	_ "embed"
+	"embed"
+	"errors"
+	"fmt"
 	"math/rand"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
 	"strings"
 )
 
-var AllPieces []Piece
+//go:embed pieces/*.txt
+var pieceSetFiles embed.FS
+
+// PieceSet is a named collection of pieces that a game deals from.
+type PieceSet struct {
+	Name   string
+	Pieces []Piece
+}
+
+// ClassicPieceSet is the name of the original piece set, which games use unless they choose another.
+const ClassicPieceSet = "classic"
+
+var (
+	// AllPieces are the pieces of the classic set.
+	AllPieces []Piece
+
+	pieceSets = make(map[string]PieceSet)
+	// PieceSetNames lists the registered piece sets, starting with the classic set.
+	PieceSetNames []string
+)
 
 func init() {
-	AllPieces = []Piece{
-		/* 0 */ parsePiece("#"),
-		/* 1 */ parsePiece("##"),
-		/* 2 */ parsePiece("###"),
-		/* 3 */ parsePiece("####"),
-		/* 4 */ parsePiece("#####"),
-		/* 5 */ parsePiece("##\n##"),
-		/* 6 */ parsePiece("###\n###\n###"),
-		/* 7 */ parsePiece("###\n # "),
-		/* 8 */ parsePiece("# \n# \n##"),
-		/* 9 */ parsePiece(" #\n #\n##"),
-		/* 10 */ parsePiece(" ##\n## "),
-		/* 11 */ parsePiece("## \n ##"),
-		/* 12 */ parsePiece("##\n #"),
-		/* 13 */ parsePiece("# \n##"),
-		/* 14 */ parsePiece("#  \n # \n  #"),
-		/* 15 */ parsePiece("# \n #\n"),
-		/* 16 */ parsePiece("###\n###"),
-		/* 17 */ parsePiece("#  \n#  \n###"),
-		/* 18 */ parsePiece("  #\n  #\n###"),
+	for _, name := range []string{ClassicPieceSet, "tetrominoes", "pentominoes", "big"} {
+		data, err := pieceSetFiles.ReadFile(path.Join("pieces", name+".txt"))
+		if err != nil {
+			panic(err)
+		}
+		set, err := ParsePieceSet(name, string(data))
+		if err != nil {
+			panic(err)
+		}
+		if err := RegisterPieceSet(set); err != nil {
+			panic(err)
+		}
 	}
+	AllPieces = pieceSets[ClassicPieceSet].Pieces
 }
 
-func parsePiece(pieceStr string) Piece {
-	rows := strings.Split(pieceStr, "\n")
-	shape := make([][]bool, 0, len(rows))
-	for _, row := range rows {
-		if len(row) == 0 {
+// RegisterPieceSet makes a piece set available to games by name.
+func RegisterPieceSet(set PieceSet) error {
+	if _, ok := pieceSets[set.Name]; ok {
+		return fmt.Errorf("piece set %q is already registered", set.Name)
+	}
+	pieceSets[set.Name] = set
+	PieceSetNames = append(PieceSetNames, set.Name)
+	return nil
+}
+
+// LookupPieceSet returns the registered piece set with the name.  An empty name is the classic set.
+func LookupPieceSet(name string) (PieceSet, error) {
+	if name == "" {
+		name = ClassicPieceSet
+	}
+	set, ok := pieceSets[name]
+	if !ok {
+		return PieceSet{}, fmt.Errorf("unknown piece set %q", name)
+	}
+	return set, nil
+}
+
+// ReadPieceSetFile parses a piece set from a file, naming it after the file without its extension.
+func ReadPieceSetFile(filename string) (PieceSet, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return PieceSet{}, err
+	}
+	name := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	return ParsePieceSet(name, string(data))
+}
+
+// ParsePieceSet parses piece definitions.  Each piece starts with a line of the form "> name [weight]" and is followed
+// by the rows of its shape, using "#" for blocks and "." or spaces for gaps.  A name of the form "name/mirror" also
+// adds the piece's mirror image, named mirror, right after it; the piece must be chiral, since otherwise its mirror
+// image is just one of its rotations.  Blank lines and lines starting with "//" are ignored between pieces.
+func ParsePieceSet(name, text string) (PieceSet, error) {
+	set := PieceSet{Name: name}
+	var (
+		piece      *Piece
+		mirrorName string
+		rows       []string
+		startLine  int
+	)
+	finishPiece := func() error {
+		if piece == nil {
+			return nil
+		}
+		parsed, err := parseShape(rows)
+		if err != nil {
+			return fmt.Errorf("%s:%d: piece %q: %w", name, startLine, piece.Name, err)
+		}
+		piece.Shape = parsed.Shape
+		piece.Color = pieceColor(len(set.Pieces))
+		set.Pieces = append(set.Pieces, *piece)
+		if mirrorName != "" {
+			if !piece.Chiral() {
+				return fmt.Errorf("%s:%d: piece %q: %w", name, startLine, piece.Name, ErrNotChiral)
+			}
+			mirror := piece.Flip()
+			mirror.Name = mirrorName
+			mirror.Color = pieceColor(len(set.Pieces))
+			set.Pieces = append(set.Pieces, mirror)
+		}
+		piece, mirrorName, rows = nil, "", nil
+		return nil
+	}
+	names := make(map[string]bool)
+	for i, line := range strings.Split(text, "\n") {
+		lineNum := i + 1
+		line = strings.TrimRight(line, " \r")
+		switch {
+		case strings.HasPrefix(line, "//"):
 			continue
+		case strings.HasPrefix(line, ">"):
+			if err := finishPiece(); err != nil {
+				return PieceSet{}, err
+			}
+			fields := strings.Fields(line[1:])
+			if len(fields) == 0 || len(fields) > 2 {
+				return PieceSet{}, fmt.Errorf("%s:%d: expected \"> name [weight]\"", name, lineNum)
+			}
+			pieceName, mirror, hasMirror := strings.Cut(fields[0], "/")
+			if pieceName == "" || (hasMirror && mirror == "") {
+				return PieceSet{}, fmt.Errorf("%s:%d: expected \"> name [weight]\"", name, lineNum)
+			}
+			for _, n := range []string{pieceName, mirror} {
+				if names[n] {
+					return PieceSet{}, fmt.Errorf("%s:%d: duplicate piece %q", name, lineNum, n)
+				}
+				if n != "" {
+					names[n] = true
+				}
+			}
+			piece = &Piece{Name: pieceName, Weight: 1}
+			mirrorName = mirror
+			startLine = lineNum
+			if len(fields) == 2 {
+				weight, err := strconv.Atoi(fields[1])
+				if err != nil || weight <= 0 {
+					return PieceSet{}, fmt.Errorf("%s:%d: weight %q must be a positive integer", name, lineNum, fields[1])
+				}
+				piece.Weight = weight
+			}
+		case line == "":
+			if err := finishPiece(); err != nil {
+				return PieceSet{}, err
+			}
+		default:
+			if piece == nil {
+				return PieceSet{}, fmt.Errorf("%s:%d: shape row outside of a piece", name, lineNum)
+			}
+			rows = append(rows, line)
 		}
-		pieceRow := make([]bool, len(row))
+	}
+	if err := finishPiece(); err != nil {
+		return PieceSet{}, err
+	}
+	if len(set.Pieces) == 0 {
+		return PieceSet{}, fmt.Errorf("%s: no pieces", name)
+	}
+	return set, nil
+}
+
+// pieceColor returns the color of the piece at the index in its set.
+func pieceColor(index int) Color {
+	return Color(index%NumPieceColors + 1)
+}
+
+var (
+	ErrEmptyShape  = errors.New("shape has no blocks")
+	ErrShapeBorder = errors.New("shape has an empty row or column at its edge")
+	ErrNotChiral   = errors.New("mirror image is a rotation of the piece")
+)
+
+// ParsePiece parses a single shape drawn with "#" for blocks and "." or spaces for gaps, one row per line.  Shorter
+// rows are padded with gaps.
+func ParsePiece(pieceStr string) (Piece, error) {
+	return parseShape(strings.Split(strings.Trim(pieceStr, "\n"), "\n"))
+}
+
+func parseShape(rows []string) (Piece, error) {
+	var width int
+	for _, row := range rows {
+		width = max(width, len(row))
+	}
+	shape := make([][]bool, 0, len(rows))
+	var numBlocks int
+	for r, row := range rows {
+		pieceRow := make([]bool, width)
 		for i, c := range row {
-			if c != ' ' {
+			switch c {
+			case '#':
 				pieceRow[i] = true
+				numBlocks++
+			case '.', ' ':
+			default:
+				return Piece{}, fmt.Errorf("row %d: unexpected %q, use \"#\" for blocks and \".\" for gaps", r+1, c)
 			}
 		}
 		shape = append(shape, pieceRow)
 	}
-	return Piece{
-		Shape: shape,
+	if numBlocks == 0 {
+		return Piece{}, ErrEmptyShape
+	}
+	piece := Piece{Shape: shape}
+	var firstCol, lastCol bool
+	for r := range shape {
+		firstCol = firstCol || shape[r][0]
+		lastCol = lastCol || shape[r][width-1]
+	}
+	if !firstCol || !lastCol || !rowHasBlock(shape[0]) || !rowHasBlock(shape[len(shape)-1]) {
+		return Piece{}, ErrShapeBorder
 	}
+	return piece, nil
 }
 
-func RandomRotatedPiece(randSource rand.Source) Piece {
-	randPieceIdx := randSource.Int63() % int64(len(AllPieces))
-	piece := AllPieces[randPieceIdx]
-	rotateTimes := int(randSource.Int63() % 4)
-	for i := 0; i < rotateTimes; i++ {
-		piece = piece.Rotate()
+func rowHasBlock(row []bool) bool {
+	for _, block := range row {
+		if block {
+			return true
+		}
+	}
+	return false
+}
+
+// parsePiece parses a shape that is known to be valid.
+func parsePiece(pieceStr string) Piece {
+	piece, err := ParsePiece(pieceStr)
+	if err != nil {
+		panic(err)
 	}
 	return piece
 }
+
+// RandomRotatedPiece deals a piece from the classic set.
+func RandomRotatedPiece(randSource rand.Source) Piece {
+	return pieceSets[ClassicPieceSet].RandomRotatedPiece(randSource)
+}
+
+// RandomRotatedPiece picks a piece uniformly and rotates it a random number of times.
+func (s PieceSet) RandomRotatedPiece(randSource rand.Source) Piece {
+	randPieceIdx := randSource.Int63() % int64(len(s.Pieces))
+	return randomRotation(s.Pieces[randPieceIdx], randSource)
+}
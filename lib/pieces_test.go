@@ -9,3 +9,94 @@ import (
 func TestParsePieces(t *testing.T) {
 	require.Equal(t, Piece{Shape: [][]bool{{false, true, false}, {true, true, true}}}, parsePiece(" # \n###"))
 }
+
+func TestParsePieceErrors(t *testing.T) {
+	_, err := ParsePiece("...\n...")
+	require.ErrorIs(t, err, ErrEmptyShape)
+	_, err = ParsePiece("##\n..")
+	require.ErrorIs(t, err, ErrShapeBorder)
+	_, err = ParsePiece("#x")
+	require.ErrorContains(t, err, `unexpected 'x'`)
+
+	piece, err := ParsePiece("#\n##")
+	require.NoError(t, err)
+	require.Equal(t, [][]bool{{true, false}, {true, true}}, piece.Shape)
+}
+
+func TestParsePieceSet(t *testing.T) {
+	set, err := ParsePieceSet("test", `// a comment
+> bar 3
+###
+
+> corner
+#.
+##
+`)
+	require.NoError(t, err)
+	require.Len(t, set.Pieces, 2)
+	require.Equal(t, "bar", set.Pieces[0].Name)
+	require.Equal(t, 3, set.Pieces[0].Weight)
+	require.Equal(t, 1, set.Pieces[1].Weight)
+	require.Equal(t, 3, set.Pieces[1].NumBlocks())
+
+	for text, message := range map[string]string{
+		"###":             "test:1: shape row outside of a piece",
+		"> a\n#\n> a\n#":  `test:3: duplicate piece "a"`,
+		"> a 0\n#":        `test:1: weight "0" must be a positive integer`,
+		"> a\n\n> b\n##":  `test:1: piece "a": shape has no blocks`,
+		"// nothing here": "test: no pieces",
+	} {
+		_, err := ParsePieceSet("test", text)
+		require.EqualError(t, err, message, text)
+	}
+}
+
+func TestLookupPieceSet(t *testing.T) {
+	classic, err := LookupPieceSet("")
+	require.NoError(t, err)
+	require.Equal(t, AllPieces, classic.Pieces)
+	require.Len(t, classic.Pieces, 19)
+	require.Equal(t, ClassicPieceSet, PieceSetNames[0])
+
+	for _, name := range PieceSetNames {
+		set, err := LookupPieceSet(name)
+		require.NoError(t, err)
+		require.Equal(t, name, set.Name)
+	}
+	_, err = LookupPieceSet("nope")
+	require.Error(t, err)
+}
+
+func TestOrientations(t *testing.T) {
+	square := parsePiece("##\n##")
+	require.Len(t, square.Rotations(), 1)
+	require.Len(t, square.Orientations(), 1)
+	require.False(t, square.Chiral())
+
+	bar := parsePiece("###")
+	require.Len(t, bar.Rotations(), 2)
+	require.Len(t, bar.Orientations(), 2)
+
+	l := parsePiece("#.\n#.\n##")
+	require.True(t, l.Chiral())
+	require.Len(t, l.Rotations(), 4)
+	require.Len(t, l.Orientations(), 8)
+	require.True(t, l.Flip().Equal(parsePiece(".#\n.#\n##")))
+	require.True(t, l.Normalize().Equal(l.Flip().Rotate().Normalize()))
+	require.False(t, l.Normalize().Equal(bar.Normalize()))
+	require.Equal(t, "#.\n#.\n##", l.String())
+}
+
+func TestParsePieceSetMirrors(t *testing.T) {
+	set, err := ParsePieceSet("test", "> l/j 2\n#.\n#.\n##")
+	require.NoError(t, err)
+	require.Len(t, set.Pieces, 2)
+	require.Equal(t, "j", set.Pieces[1].Name)
+	require.Equal(t, 2, set.Pieces[1].Weight)
+	require.True(t, set.Pieces[1].Equal(set.Pieces[0].Flip()))
+
+	_, err = ParsePieceSet("test", "> t/u\n###\n.#.")
+	require.ErrorIs(t, err, ErrNotChiral)
+	_, err = ParsePieceSet("test", "> a\n#\n\n> l/a\n#.\n##")
+	require.EqualError(t, err, `test:4: duplicate piece "a"`)
+}
@@ -0,0 +1,111 @@
+package lib
+
+import "iter"
+
+// Placement is a legal location for a piece along with the outcome of placing it there.
+type Placement struct {
+	PieceLocation
+	ClearedRows []int
+	ClearedCols []int
+	// ClearedBoxes are the indexes of the boxes cleared in games with Boxes.
+	ClearedBoxes []int
+	// Points is the total of Breakdown, which is scored by the classic rules unless the placement was made through a
+	// Session.
+	Points    int
+	Breakdown ScoreBreakdown
+	// Result is the board occupancy after the piece is placed and any full lines are cleared, packed for the board
+	// that made the placement.  Grid unpacks it.
+	Result Bitboard
+
+	// width and height are the size of the board that made the placement.
+	width, height int
+}
+
+// Grid returns the board after the piece is placed and any full lines are cleared.
+func (p Placement) Grid() Grid {
+	grid := NewGrid(p.width, p.height)
+	for r := range grid {
+		for c := range grid[r] {
+			if p.Result.Has(r*p.width + c) {
+				grid[r][c] = Occupied
+			}
+		}
+	}
+	return grid
+}
+
+// NumClearedLines returns the number of rows, columns and boxes cleared.
+func (p Placement) NumClearedLines() int {
+	return len(p.ClearedRows) + len(p.ClearedCols) + len(p.ClearedBoxes)
+}
+
+// placement computes the outcome of placing the masked piece at loc, or returns false if it doesn't fit.  The caller
+// scores it.
+func (b *Board) placement(mask PieceMask, pieceLoc PieceLocation) (Placement, bool) {
+	if !b.Fits(mask, pieceLoc.Loc) {
+		return Placement{}, false
+	}
+	loc := pieceLoc.Loc
+	occupancy := b.Occupancy().Or(mask.Bits.ShiftLeft(loc.R*b.width + loc.C))
+	clearedRows, clearedCols, clearedBoxes, cleared := b.fullLines(occupancy, mask, loc)
+	result := occupancy.AndNot(cleared)
+	placement := Placement{
+		PieceLocation: pieceLoc,
+		ClearedRows:   clearedRows,
+		ClearedCols:   clearedCols,
+		ClearedBoxes:  clearedBoxes,
+		Result:        result,
+		width:         b.width,
+		height:        b.height,
+	}
+	return placement, true
+}
+
+// placements yields the placement at every location where the masked piece fits on the current board, in row-major
+// order, unscored.  It's the one scan over locations that legal moves, game over and the solver all share.  Each
+// placement is computed from the board as it is when the placement is yielded, so the caller can place a piece and
+// take it back between them.
+func (b *Board) placements(mask PieceMask, piece Piece) iter.Seq[Placement] {
+	return func(yield func(Placement) bool) {
+		for r := 0; r+mask.Height <= b.height; r++ {
+			for c := 0; c+mask.Width <= b.width; c++ {
+				placement, ok := b.placement(mask, PieceLocation{Piece: piece, Loc: Location{C: c, R: r}})
+				if ok && !yield(placement) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// score sets the points for the placement of a piece with numBlocks blocks following streak placements that cleared
+// lines.  singleColorLines is the number of the lines it clears that are made of a single color.
+func (p *Placement) score(scorer Scorer, numBlocks, streak, singleColorLines int) {
+	p.Breakdown = scorer.Score(PlacementResult{
+		NumBlocks:        numBlocks,
+		ClearedRows:      p.ClearedRows,
+		ClearedCols:      p.ClearedCols,
+		ClearedBoxes:     p.ClearedBoxes,
+		EmptyBoard:       p.Result.IsZero(),
+		Streak:           streak,
+		SingleColorLines: singleColorLines,
+	})
+	p.Points = p.Breakdown.Total()
+}
+
+// LegalPlacements returns every location where the piece fits on the current board, in row-major order, along with
+// the outcome of placing it there.
+func (b *Board) LegalPlacements(piece Piece) []Placement {
+	mask := b.PieceMask(piece)
+	var placements []Placement
+	for placement := range b.placements(mask, piece) {
+		placement.score(ClassicScorer{}, mask.NumBlocks, 0, 0)
+		placements = append(placements, placement)
+	}
+	return placements
+}
+
+// Apply commits a placement previously returned for the current board.
+func (b *Board) Apply(placement Placement) {
+	b.history.Push(placement.Result)
+}
@@ -0,0 +1,104 @@
+package lib
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/bits"
+	"math/rand"
+)
+
+// Xoshiro is the xoshiro256** generator seeded through splitmix64.  Unlike the sources in math/rand its sequence is
+// defined here, so it can't change with the Go version.  It implements rand.Source64.
+type Xoshiro struct {
+	state [4]uint64
+}
+
+func NewXoshiro(seed uint64) *Xoshiro {
+	x := &Xoshiro{}
+	x.Seed(int64(seed))
+	return x
+}
+
+// Seed resets the state by running splitmix64 from the seed, which spreads even small seeds over all the state bits.
+func (x *Xoshiro) Seed(seed int64) {
+	sm := uint64(seed)
+	for i := range x.state {
+		sm += 0x9e3779b97f4a7c15
+		z := sm
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		x.state[i] = z ^ (z >> 31)
+	}
+}
+
+func (x *Xoshiro) Uint64() uint64 {
+	s := &x.state
+	result := bits.RotateLeft64(s[1]*5, 7) * 9
+	t := s[1] << 17
+	s[2] ^= s[0]
+	s[3] ^= s[1]
+	s[1] ^= s[2]
+	s[0] ^= s[3]
+	s[2] ^= t
+	s[3] = bits.RotateLeft64(s[3], 45)
+	return result
+}
+
+func (x *Xoshiro) Int63() int64 {
+	return int64(x.Uint64() >> 1)
+}
+
+const xoshiroStateSize = 32
+
+// MarshalBinary returns the generator's state, so it can be saved and restored to continue the same sequence.
+func (x *Xoshiro) MarshalBinary() ([]byte, error) {
+	data := make([]byte, 0, xoshiroStateSize)
+	for _, word := range x.state {
+		data = binary.BigEndian.AppendUint64(data, word)
+	}
+	return data, nil
+}
+
+func (x *Xoshiro) UnmarshalBinary(data []byte) error {
+	if len(data) != xoshiroStateSize {
+		return fmt.Errorf("xoshiro state is %d bytes, want %d", len(data), xoshiroStateSize)
+	}
+	var state [4]uint64
+	for i := range state {
+		state[i] = binary.BigEndian.Uint64(data[i*8:])
+	}
+	if state == [4]uint64{} {
+		return errors.New("xoshiro state can't be all zeros")
+	}
+	x.state = state
+	return nil
+}
+
+// RulesVersion identifies how a game ID turns into the pieces dealt.  It's recorded with every game and carried in
+// game links, so a game keeps dealing the same pieces after the code changes.  Any change that alters the pieces dealt
+// for a game ID, such as a new random source or a different way of drawing from it, needs a new version.
+type RulesVersion int
+
+const (
+	// RulesLegacy deals from math/rand's source.  It's the version of games recorded and links shared before versions
+	// existed.
+	RulesLegacy RulesVersion = 0
+	// RulesXoshiro deals from Xoshiro.
+	RulesXoshiro RulesVersion = 1
+
+	// CurrentRulesVersion is the version new games use.
+	CurrentRulesVersion = RulesXoshiro
+)
+
+// NewSource returns the random source that deals the pieces of the game with the ID.
+func (v RulesVersion) NewSource(gameID uint64) (rand.Source, error) {
+	switch v {
+	case RulesLegacy:
+		return rand.NewSource(int64(gameID)), nil
+	case RulesXoshiro:
+		return NewXoshiro(gameID), nil
+	default:
+		return nil, fmt.Errorf("unknown rules version %d", int(v))
+	}
+}
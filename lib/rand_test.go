@@ -0,0 +1,54 @@
+package lib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestXoshiroMatchesReference(t *testing.T) {
+	x := NewXoshiro(0)
+	require.Equal(t, uint64(0x99ec5f36cb75f2b4), x.Uint64())
+	require.Equal(t, uint64(0xbf6e1f784956452a), x.Uint64())
+
+	x = NewXoshiro(42)
+	require.Equal(t, uint64(0x15780b2e0c2ec716), x.Uint64())
+	require.Equal(t, int64(0x6104d9866d113a7e>>1), x.Int63())
+}
+
+func TestXoshiroStateRoundTrips(t *testing.T) {
+	x := NewXoshiro(7)
+	x.Uint64()
+	state, err := x.MarshalBinary()
+	require.NoError(t, err)
+
+	var restored Xoshiro
+	require.NoError(t, restored.UnmarshalBinary(state))
+	for range 10 {
+		require.Equal(t, x.Uint64(), restored.Uint64())
+	}
+	require.Error(t, restored.UnmarshalBinary(state[:8]))
+	require.Error(t, restored.UnmarshalBinary(make([]byte, xoshiroStateSize)))
+}
+
+// TestRulesVersionsKeepDealing pins the first tray of a game under every rules version.  If it fails, the pieces
+// dealt for existing game IDs have changed and shared links no longer reproduce their games.
+func TestRulesVersionsKeepDealing(t *testing.T) {
+	for version, want := range map[RulesVersion][]string{
+		RulesLegacy:  {"###\n..#\n..#", "#", "#..\n#..\n###"},
+		RulesXoshiro: {"###\n###\n###", "##\n##", ".#\n.#\n##"},
+	} {
+		config := DefaultConfig
+		config.Version = version
+		s, err := NewSession(42, config)
+		require.NoError(t, err)
+		var tray []string
+		for _, piece := range s.Tray() {
+			tray = append(tray, piece.String())
+		}
+		require.Equal(t, want, tray, "version %d", version)
+	}
+
+	_, err := RulesVersion(99).NewSource(1)
+	require.Error(t, err)
+}
@@ -0,0 +1,155 @@
+package lib
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	pointsPerLine    = 10
+	pointsPerBox     = 10
+	emptyBoardPoints = 300
+
+	// colorPointsPerLine is the bonus for each cleared line whose blocks all have the same color.
+	colorPointsPerLine = 20
+
+	// comboPointsPerLine is the extra points for each line beyond the first cleared by a single placement, for each
+	// line it clears.  Clearing 2 lines at once earns 2*10 + 2*1*10 = 40 rather than 20.
+	comboPointsPerLine = 10
+	// streakPoints is the bonus for each consecutive earlier placement that also cleared lines.
+	streakPoints = 10
+	maxStreak    = 10
+)
+
+// PlacementResult is what a scorer needs to know about a placement.
+type PlacementResult struct {
+	NumBlocks    int
+	ClearedRows  []int
+	ClearedCols  []int
+	ClearedBoxes []int
+	// EmptyBoard is set if the placement left the board empty.
+	EmptyBoard bool
+	// Streak is the number of placements in a row before this one that cleared lines.
+	Streak int
+	// SingleColorLines is the number of cleared lines whose blocks, including the piece's, all have the same color.
+	SingleColorLines int
+}
+
+// NumClearedLines returns the number of rows, columns and boxes cleared.
+func (r PlacementResult) NumClearedLines() int {
+	return len(r.ClearedRows) + len(r.ClearedCols) + len(r.ClearedBoxes)
+}
+
+// ScoreBreakdown itemizes the points awarded for a placement.
+type ScoreBreakdown struct {
+	Blocks     int
+	Lines      int
+	Boxes      int
+	Combo      int
+	Streak     int
+	EmptyBoard int
+	Color      int
+}
+
+func (b ScoreBreakdown) Total() int {
+	return b.Blocks + b.Lines + b.Boxes + b.Combo + b.Streak + b.EmptyBoard + b.Color
+}
+
+// String lists the non-zero parts of the breakdown, such as "+4 +20 lines +20 combo".
+func (b ScoreBreakdown) String() string {
+	parts := []string{fmt.Sprintf("+%d", b.Blocks)}
+	for _, part := range []struct {
+		points int
+		label  string
+	}{
+		{b.Lines, "lines"},
+		{b.Boxes, "boxes"},
+		{b.Combo, "combo"},
+		{b.Streak, "streak"},
+		{b.EmptyBoard, "clear"},
+		{b.Color, "color"},
+	} {
+		if part.points != 0 {
+			parts = append(parts, fmt.Sprintf("+%d %s", part.points, part.label))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// Scorer awards points for placements.
+type Scorer interface {
+	Score(result PlacementResult) ScoreBreakdown
+}
+
+// ClassicScorer awards a point per block, 10 per cleared line or box and 300 for emptying the board.
+type ClassicScorer struct{}
+
+func (ClassicScorer) Score(result PlacementResult) ScoreBreakdown {
+	breakdown := ScoreBreakdown{
+		Blocks: result.NumBlocks,
+		Lines:  (len(result.ClearedRows) + len(result.ClearedCols)) * pointsPerLine,
+		Boxes:  len(result.ClearedBoxes) * pointsPerBox,
+	}
+	if result.EmptyBoard {
+		breakdown.EmptyBoard = emptyBoardPoints
+	}
+	return breakdown
+}
+
+// ComboScorer scores like ClassicScorer but makes clearing several lines at once worth more than clearing them one
+// at a time, and adds a bonus for clearing lines on consecutive placements.
+type ComboScorer struct{}
+
+func (ComboScorer) Score(result PlacementResult) ScoreBreakdown {
+	breakdown := ClassicScorer{}.Score(result)
+	lines := result.NumClearedLines()
+	if lines == 0 {
+		return breakdown
+	}
+	breakdown.Combo = lines * (lines - 1) * comboPointsPerLine
+	breakdown.Streak = min(result.Streak, maxStreak) * streakPoints
+	return breakdown
+}
+
+// colorBonusScorer scores like its Scorer and adds a bonus for each cleared line made of a single color.
+type colorBonusScorer struct {
+	Scorer
+}
+
+func (s colorBonusScorer) Score(result PlacementResult) ScoreBreakdown {
+	breakdown := s.Scorer.Score(result)
+	breakdown.Color = result.SingleColorLines * colorPointsPerLine
+	return breakdown
+}
+
+// ScoringRules names a scorer so it can be chosen per game and recorded.
+type ScoringRules string
+
+const (
+	ScoringClassic ScoringRules = ""
+	ScoringCombo   ScoringRules = "combo"
+)
+
+var scoringRulesToScorer = map[ScoringRules]Scorer{
+	ScoringClassic: ClassicScorer{},
+	ScoringCombo:   ComboScorer{},
+}
+
+// AllScoringRules lists the available scoring rules, starting with the default.
+var AllScoringRules = []ScoringRules{ScoringClassic, ScoringCombo}
+
+func (r ScoringRules) String() string {
+	if r == ScoringClassic {
+		return "classic"
+	}
+	return string(r)
+}
+
+// Scorer returns the scorer for the rules.
+func (r ScoringRules) Scorer() (Scorer, error) {
+	scorer, ok := scoringRulesToScorer[r]
+	if !ok {
+		return nil, fmt.Errorf("unknown scoring rules %q", string(r))
+	}
+	return scorer, nil
+}
@@ -0,0 +1,99 @@
+package lib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestComboScorer(t *testing.T) {
+	result := PlacementResult{NumBlocks: 5, ClearedRows: []int{1, 2}, ClearedCols: []int{4}, Streak: 2}
+	require.Equal(t, ScoreBreakdown{Blocks: 5, Lines: 30}, ClassicScorer{}.Score(result))
+
+	breakdown := ComboScorer{}.Score(result)
+	require.Equal(t, ScoreBreakdown{Blocks: 5, Lines: 30, Combo: 60, Streak: 20}, breakdown)
+	require.Equal(t, 115, breakdown.Total())
+	require.Equal(t, "+5 +30 lines +60 combo +20 streak", breakdown.String())
+
+	result.ClearedRows, result.ClearedCols = nil, nil
+	require.Equal(t, ScoreBreakdown{Blocks: 5}, ComboScorer{}.Score(result))
+}
+
+func TestSessionTracksStreak(t *testing.T) {
+	s, err := NewSession(1, Config{Width: 1, Height: 1, Scoring: ScoringCombo})
+	require.NoError(t, err)
+	var points []int
+	for range 3 {
+		placement, err := s.Play(Move{Slot: CheatSlot})
+		require.NoError(t, err)
+		points = append(points, placement.Points)
+	}
+	// Every single block clears its row and column and empties the board.
+	require.Equal(t, []int{1 + 20 + 20 + 300, 1 + 20 + 20 + 10 + 300, 1 + 20 + 20 + 20 + 300}, points)
+
+	_, err = NewSession(1, Config{Width: 1, Height: 1, Scoring: "unknown"})
+	require.Error(t, err)
+}
+
+func TestColorBonus(t *testing.T) {
+	s, err := NewSession(1, Config{Width: 3, Height: 2, ColorBonus: true})
+	require.NoError(t, err)
+	domino := parsePiece("##")
+	domino.Color = 3
+	s.tray[0] = &domino
+	_, err = s.Play(Move{Slot: 0, Loc: Location{C: 0, R: 1}})
+	require.NoError(t, err)
+	require.Equal(t, [][]Color{{NoColor, NoColor, NoColor}, {3, 3, NoColor}}, s.Colors())
+
+	// A block of another color makes the row mixed.
+	placement, err := s.Play(Move{Slot: CheatSlot, Loc: Location{C: 2, R: 1}})
+	require.NoError(t, err)
+	require.Equal(t, ScoreBreakdown{Blocks: 1, Lines: 10, EmptyBoard: 300}, placement.Breakdown)
+
+	bar := parsePiece("###")
+	bar.Color = 5
+	s.tray[1] = &bar
+	placement, err = s.Play(Move{Slot: 1, Loc: Location{C: 0, R: 0}})
+	require.NoError(t, err)
+	require.Equal(t, ScoreBreakdown{Blocks: 3, Lines: 10, EmptyBoard: 300, Color: 20}, placement.Breakdown)
+	require.Equal(t, [][]Color{{NoColor, NoColor, NoColor}, {NoColor, NoColor, NoColor}}, s.Colors())
+}
+
+func TestSolveScoresColorBonus(t *testing.T) {
+	s, err := NewSession(1, Config{Width: 3, Height: 2, ColorBonus: true})
+	require.NoError(t, err)
+	domino := parsePiece("##")
+	domino.Color = 3
+	s.tray[0] = &domino
+	_, err = s.Play(Move{Slot: 0, Loc: Location{C: 0, R: 1}})
+	require.NoError(t, err)
+
+	single := parsePiece("#")
+	single.Color = 3
+	s.tray[1], s.tray[2] = &single, nil
+	plan, ok := s.Solve(ScoreEvaluator)
+	require.True(t, ok)
+	placement, err := s.Play(Move{Slot: plan.Slots[0], Loc: plan.Placements[0].Loc})
+	require.NoError(t, err)
+	require.Equal(t, 20, placement.Breakdown.Color)
+	require.Equal(t, placement.Points, plan.Outcome.Points)
+}
+
+func TestBoxes(t *testing.T) {
+	s, err := NewSession(1, Config{Width: 6, Height: 6, Boxes: true})
+	require.NoError(t, err)
+	var placement Placement
+	for _, loc := range s.Board().BoxCells(3) {
+		placement, err = s.Play(Move{Slot: CheatSlot, Loc: loc})
+		require.NoError(t, err)
+	}
+	// The last block completes the bottom right box, which is cleared without a full row or column.
+	require.Equal(t, []int{3}, placement.ClearedBoxes)
+	require.Empty(t, placement.ClearedRows)
+	require.Empty(t, placement.ClearedCols)
+	require.Equal(t, ScoreBreakdown{Blocks: 1, Boxes: 10, EmptyBoard: 300}, placement.Breakdown)
+	require.Equal(t, 1, s.Lines())
+
+	_, err = NewSession(1, Config{Width: 8, Height: 8, Boxes: true})
+	require.Error(t, err)
+}
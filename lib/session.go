@@ -0,0 +1,523 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+)
+
+// TraySize is the number of pieces dealt at a time.
+const TraySize = 3
+
+// CheatSlot is the slot of a move that placed a single block without using a tray piece.
+const CheatSlot = -1
+
+// CheatPiece is the piece placed by a CheatSlot move.
+var CheatPiece = parsePiece("#")
+
+// Move is a committed placement: the tray slot the piece came from and where its top-left cell went.
+type Move struct {
+	Slot int      `json:"slot"`
+	Loc  Location `json:"loc"`
+	// Rotation is the number of quarter turns the player gave the piece before placing it.  It's only allowed in games
+	// with PlayerRotation.
+	Rotation int `json:"rot,omitempty"`
+}
+
+// Config holds the rules chosen for a game.
+type Config struct {
+	// Version is how the game ID turns into pieces.  Games recorded before versions existed have the zero value,
+	// RulesLegacy.
+	Version RulesVersion `json:"version,omitempty"`
+	Width   int          `json:"width"`
+	Height  int          `json:"height"`
+	Scoring ScoringRules `json:"scoring,omitempty"`
+	// Pieces names the piece set to deal from.  Empty means the classic set.
+	Pieces string `json:"pieces,omitempty"`
+	// Distribution is how pieces are drawn from the set.
+	Distribution PieceDistribution `json:"distribution,omitempty"`
+	// PlayerRotation lets the player rotate pieces before placing them.
+	PlayerRotation bool `json:"playerRotation,omitempty"`
+	// ColorBonus adds points for clearing lines made of a single color.
+	ColorBonus bool `json:"colorBonus,omitempty"`
+	// Boxes clears full boxes of BoxSize by BoxSize cells as well as full lines, as in sudoku.  The board's sides must
+	// be multiples of BoxSize.
+	Boxes bool `json:"boxes,omitempty"`
+	// Level names the built-in level being played, which sets the starting board and the pieces dealt.  Empty means
+	// an endless game dealt from the game ID.
+	Level string `json:"level,omitempty"`
+}
+
+// DefaultConfig is the classic game on the default board.
+var DefaultConfig = Config{
+	Version: CurrentRulesVersion,
+	Width:   DefaultBoardSize,
+	Height:  DefaultBoardSize,
+}
+
+// Record is everything needed to reproduce a game.
+type Record struct {
+	GameID uint64 `json:"gameID"`
+	Config
+	Moves []Move `json:"moves"`
+	Score int64  `json:"score"`
+	// Undos is the number of moves taken back and Penalty the points they cost.
+	Undos   int   `json:"undos,omitempty"`
+	Penalty int64 `json:"penalty,omitempty"`
+}
+
+// Session plays a game by the rules: it deals a tray of random pieces generated from the game ID, scores each move and
+// deals a new tray once every piece in the current one has been placed.  Every move is recorded so the game can be
+// replayed.
+type Session struct {
+	gameID    uint64
+	config    Config
+	board     Board
+	scorer    Scorer
+	pieces    PieceSet
+	generator PieceGenerator
+	tray      [TraySize]*Piece
+	points    int64
+	moves     []Move
+	lines     int
+	colors    cellColors
+	// level is the level being played, or nil in an endless game.  dealt counts the pieces dealt from it so far.
+	level *Level
+	dealt int
+	// streak is the number of moves in a row, up to the last one, that cleared lines.
+	streak int
+
+	// redo holds the moves taken back by Undo, most recent last.
+	redo    []Move
+	undos   int
+	penalty int64
+}
+
+func NewSession(gameID uint64, config Config) (*Session, error) {
+	scorer, err := config.Scoring.Scorer()
+	if err != nil {
+		return nil, err
+	}
+	if config.ColorBonus {
+		scorer = colorBonusScorer{scorer}
+	}
+	pieces, err := LookupPieceSet(config.Pieces)
+	if err != nil {
+		return nil, err
+	}
+	randSource, err := config.Version.NewSource(gameID)
+	if err != nil {
+		return nil, err
+	}
+	generator, err := config.Distribution.Generator(pieces, randSource)
+	if err != nil {
+		return nil, err
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > MaxBoardCells {
+		return nil, fmt.Errorf("unsupported board size %dx%d", config.Width, config.Height)
+	}
+	s := &Session{
+		gameID:    gameID,
+		config:    config,
+		board:     NewBoard(config.Width, config.Height),
+		scorer:    scorer,
+		pieces:    pieces,
+		generator: generator,
+		colors:    make([]Color, config.Width*config.Height),
+	}
+	if config.Level != "" {
+		level, err := LookupLevel(config.Level)
+		if err != nil {
+			return nil, err
+		}
+		if level.Grid.Width() != config.Width || level.Grid.Height() != config.Height {
+			return nil, fmt.Errorf("level %q is %dx%d, not %dx%d", level.Name, level.Grid.Width(), level.Grid.Height(),
+				config.Width, config.Height)
+		}
+		s.level = &level
+		s.board = NewBoardFromGrid(level.Grid)
+		s.generator = &sequenceGenerator{pieces: level.Pieces}
+	}
+	if config.Boxes {
+		if config.Width%BoxSize != 0 || config.Height%BoxSize != 0 {
+			return nil, fmt.Errorf("boxes need a board whose sides are multiples of %d, not %dx%d", BoxSize,
+				config.Width, config.Height)
+		}
+		s.board.setBoxes()
+	}
+	s.deal()
+	return s, nil
+}
+
+// deal fills the tray.  In a level it stops once the level's pieces run out, leaving the rest of the tray empty.
+func (s *Session) deal() {
+	for i := range s.tray {
+		if s.level != nil && s.dealt == len(s.level.Pieces) {
+			return
+		}
+		piece := s.generator.Next()
+		s.tray[i] = &piece
+		s.dealt++
+	}
+}
+
+func (s *Session) GameID() uint64 {
+	return s.gameID
+}
+
+func (s *Session) Config() Config {
+	return s.config
+}
+
+func (s *Session) Board() *Board {
+	return &s.board
+}
+
+// Tray returns the pieces available to place.  Slots whose piece has been placed are nil.
+func (s *Session) Tray() [TraySize]*Piece {
+	return s.tray
+}
+
+// Score is the points scored by the moves less any undo penalty.
+func (s *Session) Score() int64 {
+	return s.points - s.penalty
+}
+
+// Undos returns how many moves have been taken back.
+func (s *Session) Undos() int {
+	return s.undos
+}
+
+func (s *Session) Moves() []Move {
+	return s.moves
+}
+
+// Colors returns the color of each board cell.  Empty cells are NoColor.
+func (s *Session) Colors() [][]Color {
+	colors := make([][]Color, s.board.height)
+	for r := range colors {
+		colors[r] = append([]Color(nil), s.colors[r*s.board.width:(r+1)*s.board.width]...)
+	}
+	return colors
+}
+
+// Lines returns the number of rows, columns and boxes cleared so far.
+func (s *Session) Lines() int {
+	return s.lines
+}
+
+// Level returns the level being played, and false in an endless game.
+func (s *Session) Level() (Level, bool) {
+	if s.level == nil {
+		return Level{}, false
+	}
+	return *s.level, true
+}
+
+// PiecesLeft returns the number of pieces of a level still to be placed, counting those in the tray.
+func (s *Session) PiecesLeft() int {
+	if s.level == nil {
+		return 0
+	}
+	left := len(s.level.Pieces) - s.dealt
+	for _, piece := range s.tray {
+		if piece != nil {
+			left++
+		}
+	}
+	return left
+}
+
+// Solved reports whether the level being played has reached its goal.
+func (s *Session) Solved() bool {
+	return s.level != nil && s.level.Goal.Met(s)
+}
+
+// CanMove reports whether the piece in the slot fits anywhere on the board, in any orientation the player can give
+// it.
+func (s *Session) CanMove(slot int) bool {
+	piece := s.tray[slot]
+	if piece == nil {
+		return false
+	}
+	for _, orientation := range s.orientations(*piece) {
+		if s.board.CanPlacePiece(orientation) {
+			return true
+		}
+	}
+	return false
+}
+
+// LegalMove is a move that can be played along with the placement it makes, scored by the session's rules.
+type LegalMove struct {
+	Move      Move
+	Placement Placement
+}
+
+// LegalMoves returns every move that can be played now, by slot, then rotation, then location in row-major order.
+func (s *Session) LegalMoves() []LegalMove {
+	var moves []LegalMove
+	for slot, piece := range s.tray {
+		if piece == nil {
+			continue
+		}
+		for rotation, orientation := range s.orientations(*piece) {
+			numBlocks := s.board.PieceMask(orientation).NumBlocks
+			// The board scores placements by the classic rules, so they're scored again by the session's.
+			for _, placement := range s.board.LegalPlacements(orientation) {
+				placement.score(s.scorer, numBlocks, s.streak, s.colors.singleColorLines(&s.board, placement))
+				moves = append(moves, LegalMove{
+					Move:      Move{Slot: slot, Loc: placement.Loc, Rotation: rotation},
+					Placement: placement,
+				})
+			}
+		}
+	}
+	return moves
+}
+
+// orientations returns the orientations the player can give a dealt piece: all of its rotations in games with
+// PlayerRotation and just the piece otherwise.  Each orientation's index is the number of quarter turns that produce
+// it, since turning a shape repeats its distinct rotations in order.
+func (s *Session) orientations(piece Piece) []Piece {
+	if !s.config.PlayerRotation {
+		return []Piece{piece}
+	}
+	return piece.Rotations()
+}
+
+// GameOver reports whether no piece in the tray fits on the board, or the level being played is solved.
+func (s *Session) GameOver() bool {
+	if s.Solved() {
+		return true
+	}
+	for slot := range s.tray {
+		if s.CanMove(slot) {
+			return false
+		}
+	}
+	return true
+}
+
+var (
+	ErrEmptySlot   = errors.New("no piece in slot")
+	ErrIllegalMove = errors.New("piece doesn't fit there")
+	ErrNoRotation  = errors.New("rotation isn't allowed")
+)
+
+// Play commits a move, returning the outcome of the placement.  Moves taken back by Undo can no longer be redone.
+func (s *Session) Play(move Move) (Placement, error) {
+	placement, err := s.play(move)
+	if err == nil {
+		s.redo = nil
+	}
+	return placement, err
+}
+
+func (s *Session) play(move Move) (Placement, error) {
+	var piece Piece
+	switch {
+	case move.Slot == CheatSlot:
+		piece = CheatPiece
+	case move.Slot < 0 || move.Slot >= TraySize || s.tray[move.Slot] == nil:
+		return Placement{}, fmt.Errorf("slot %d: %w", move.Slot, ErrEmptySlot)
+	default:
+		piece = *s.tray[move.Slot]
+	}
+	if move.Rotation != 0 {
+		if !s.config.PlayerRotation || move.Slot == CheatSlot || move.Rotation < 0 || move.Rotation > 3 {
+			return Placement{}, fmt.Errorf("slot %d rotated %d times: %w", move.Slot, move.Rotation, ErrNoRotation)
+		}
+		for range move.Rotation {
+			piece = piece.Rotate()
+		}
+	}
+	pieceLoc := PieceLocation{Piece: piece, Loc: move.Loc}
+	mask := s.board.PieceMask(piece)
+	placement, ok := s.board.placement(mask, pieceLoc)
+	if !ok {
+		return Placement{}, fmt.Errorf("slot %d at %+v: %w", move.Slot, move.Loc, ErrIllegalMove)
+	}
+	placement.score(s.scorer, mask.NumBlocks, s.streak, s.colors.singleColorLines(&s.board, placement))
+	if placement.NumClearedLines() > 0 {
+		s.streak++
+	} else {
+		s.streak = 0
+	}
+	s.board.Apply(placement)
+	s.colors.paint(&s.board, placement)
+	s.points += int64(placement.Points)
+	s.lines += placement.NumClearedLines()
+	s.moves = append(s.moves, move)
+	if move.Slot != CheatSlot {
+		s.tray[move.Slot] = nil
+		if s.tray == [TraySize]*Piece{} {
+			s.deal()
+		}
+	}
+	return placement, nil
+}
+
+// cellColors holds the color of each cell of a board in row-major order.  Empty cells are NoColor.
+type cellColors []Color
+
+// at returns the color cell (r, c) of the board would have after the placement, before its lines are cleared.
+func (colors cellColors) at(b *Board, placement Placement, r, c int) Color {
+	piece, loc := placement.Piece, placement.Loc
+	if r >= loc.R && r < loc.R+piece.Height() && c >= loc.C && c < loc.C+piece.Width() &&
+		piece.Shape[r-loc.R][c-loc.C] {
+		return piece.Color
+	}
+	return colors[r*b.width+c]
+}
+
+// singleColorLines counts the lines and boxes the placement on the board clears whose blocks all have the same color,
+// not counting those of blocks without one.
+func (colors cellColors) singleColorLines(b *Board, placement Placement) int {
+	var count int
+	for _, box := range placement.ClearedBoxes {
+		cells := b.BoxCells(box)
+		first := colors.at(b, placement, cells[0].R, cells[0].C)
+		single := first != NoColor
+		for _, cell := range cells[1:] {
+			single = single && colors.at(b, placement, cell.R, cell.C) == first
+		}
+		if single {
+			count++
+		}
+	}
+	for _, r := range placement.ClearedRows {
+		first := colors.at(b, placement, r, 0)
+		single := first != NoColor
+		for c := 1; single && c < b.width; c++ {
+			single = colors.at(b, placement, r, c) == first
+		}
+		if single {
+			count++
+		}
+	}
+	for _, c := range placement.ClearedCols {
+		first := colors.at(b, placement, 0, c)
+		single := first != NoColor
+		for r := 1; single && r < b.height; r++ {
+			single = colors.at(b, placement, r, c) == first
+		}
+		if single {
+			count++
+		}
+	}
+	return count
+}
+
+// hash returns an FNV-1a hash of the colors, telling apart boards with the same occupancy but different colors.
+func (colors cellColors) hash() uint64 {
+	hash := uint64(14695981039346656037)
+	for _, color := range colors {
+		hash ^= uint64(color)
+		hash *= 1099511628211
+	}
+	return hash
+}
+
+// paint updates the colors for a placement that has been applied to the board.
+func (colors cellColors) paint(b *Board, placement Placement) {
+	for i := range colors {
+		r, c := i/b.width, i%b.width
+		if placement.Result.Has(i) {
+			colors[i] = colors.at(b, placement, r, c)
+		} else {
+			colors[i] = NoColor
+		}
+	}
+}
+
+// Record returns the record of the game so far.
+func (s *Session) Record() Record {
+	return Record{
+		GameID:  s.gameID,
+		Config:  s.config,
+		Moves:   append([]Move(nil), s.moves...),
+		Score:   s.Score(),
+		Undos:   s.undos,
+		Penalty: s.penalty,
+	}
+}
+
+// rebuild replays moves from the start of the game into a new session.
+func (s *Session) rebuild(moves []Move) (*Session, error) {
+	rebuilt, err := NewSession(s.gameID, s.config)
+	if err != nil {
+		return nil, err
+	}
+	for i, move := range moves {
+		if _, err := rebuilt.play(move); err != nil {
+			return nil, fmt.Errorf("move %d: %w", i, err)
+		}
+	}
+	return rebuilt, nil
+}
+
+// Undo takes back the last move, restoring the board, tray, score and piece generator to what they were before it, and
+// adds cost to the penalty.  It returns false if there's no move to take back.
+func (s *Session) Undo(cost int64) bool {
+	if len(s.moves) == 0 {
+		return false
+	}
+	last := s.moves[len(s.moves)-1]
+	rebuilt, err := s.rebuild(s.moves[:len(s.moves)-1])
+	if err != nil {
+		// The moves were all legal when they were played, so replaying them can't fail.
+		panic(err)
+	}
+	rebuilt.redo = append(s.redo, last)
+	rebuilt.undos = s.undos + 1
+	rebuilt.penalty = s.penalty + cost
+	*s = *rebuilt
+	return true
+}
+
+// CanRedo reports whether there's a move taken back by Undo to play again.
+func (s *Session) CanRedo() bool {
+	return len(s.redo) > 0
+}
+
+// Redo plays the most recent move taken back by Undo again.  It returns false if there's none.
+func (s *Session) Redo() bool {
+	if len(s.redo) == 0 {
+		return false
+	}
+	move := s.redo[len(s.redo)-1]
+	if _, err := s.play(move); err != nil {
+		panic(err)
+	}
+	s.redo = s.redo[:len(s.redo)-1]
+	return true
+}
+
+// Replay rebuilds a game from its record, checking that every move is legal and that the final score matches.
+func Replay(record Record) (*Session, error) {
+	s, err := NewSession(record.GameID, record.Config)
+	if err != nil {
+		return nil, err
+	}
+	s, err = s.rebuild(record.Moves)
+	if err != nil {
+		return nil, err
+	}
+	s.undos = record.Undos
+	s.penalty = record.Penalty
+	if s.Score() != record.Score {
+		return nil, fmt.Errorf("replayed score %d doesn't match recorded score %d", s.Score(), record.Score)
+	}
+	return s, nil
+}
+
+// Solve finds the best plan for the current tray, scoring placements by the session's rules and counting mobility
+// with the session's piece set.  In games with PlayerRotation it tries every rotation of each piece, and in games with
+// ColorBonus it follows the colors of the cells to score single-color lines.
+func (s *Session) Solve(evaluate Evaluator) (Plan, bool) {
+	var colors cellColors
+	if s.config.ColorBonus {
+		colors = s.colors
+	}
+	return solve(&s.board, colors, s.tray[:], s.config.PlayerRotation, s.pieces.Pieces, s.scorer, s.streak, evaluate)
+}
@@ -0,0 +1,150 @@
+package lib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// playGreedily plays the first legal placement of the first movable piece until the game ends.
+func playGreedily(t *testing.T, s *Session) {
+	for !s.GameOver() {
+		tray := s.Tray()
+		for slot, piece := range tray {
+			if !s.CanMove(slot) {
+				continue
+			}
+			placements := s.Board().LegalPlacements(*piece)
+			_, err := s.Play(Move{Slot: slot, Loc: placements[0].Loc})
+			require.NoError(t, err)
+			break
+		}
+	}
+}
+
+func TestReplayReproducesGame(t *testing.T) {
+	s := newTestSession(t, 42)
+	playGreedily(t, s)
+	record := s.Record()
+	require.NotEmpty(t, record.Moves)
+
+	replayed, err := Replay(record)
+	require.NoError(t, err)
+	require.Equal(t, s.Score(), replayed.Score())
+	require.Equal(t, s.Board().Occupancy(), replayed.Board().Occupancy())
+	require.Equal(t, s.Tray(), replayed.Tray())
+
+	record.Score++
+	_, err = Replay(record)
+	require.Error(t, err)
+}
+
+func TestReplayRejectsIllegalMoves(t *testing.T) {
+	s := newTestSession(t, 7)
+	_, err := s.Play(Move{Slot: 0, Loc: Location{C: DefaultBoardSize, R: 0}})
+	require.ErrorIs(t, err, ErrIllegalMove)
+
+	_, err = s.Play(Move{Slot: 0, Loc: Location{}})
+	require.NoError(t, err)
+	_, err = s.Play(Move{Slot: 0, Loc: Location{}})
+	require.ErrorIs(t, err, ErrEmptySlot)
+
+	_, err = Replay(Record{
+		GameID: 7,
+		Config: DefaultConfig,
+		Moves:  []Move{{Slot: 0, Loc: Location{}}, {Slot: 0, Loc: Location{C: 4, R: 4}}},
+	})
+	require.ErrorIs(t, err, ErrEmptySlot)
+}
+
+func TestUndoRestoresTrayAndRandomSource(t *testing.T) {
+	s := newTestSession(t, 99)
+	var states []Record
+	var trays [][TraySize]*Piece
+	for range 5 {
+		states = append(states, s.Record())
+		trays = append(trays, s.Tray())
+		for slot := range TraySize {
+			if s.CanMove(slot) {
+				placements := s.Board().LegalPlacements(*s.Tray()[slot])
+				_, err := s.Play(Move{Slot: slot, Loc: placements[len(placements)-1].Loc})
+				require.NoError(t, err)
+				break
+			}
+		}
+	}
+	final := s.Record()
+	finalTray := s.Tray()
+
+	for i := len(states) - 1; i >= 0; i-- {
+		require.True(t, s.Undo(5))
+		require.Equal(t, states[i].Moves, s.Record().Moves)
+		require.Equal(t, trays[i], s.Tray())
+	}
+	require.False(t, s.Undo(5))
+	require.Equal(t, int64(-25), s.Score())
+
+	for s.CanRedo() {
+		require.True(t, s.Redo())
+	}
+	require.Equal(t, final.Moves, s.Record().Moves)
+	require.Equal(t, finalTray, s.Tray())
+	require.Equal(t, final.Score-25, s.Score())
+
+	replayed, err := Replay(s.Record())
+	require.NoError(t, err)
+	require.Equal(t, s.Score(), replayed.Score())
+	require.Equal(t, 5, replayed.Undos())
+}
+
+func newTestSession(t *testing.T, gameID uint64) *Session {
+	s, err := NewSession(gameID, DefaultConfig)
+	require.NoError(t, err)
+	return s
+}
+
+func TestPlayerRotation(t *testing.T) {
+	_, err := newTestSession(t, 3).Play(Move{Slot: 0, Rotation: 1})
+	require.ErrorIs(t, err, ErrNoRotation)
+
+	config := DefaultConfig
+	config.PlayerRotation = true
+	s, err := NewSession(3, config)
+	require.NoError(t, err)
+	_, err = s.Play(Move{Slot: 0, Rotation: 4})
+	require.ErrorIs(t, err, ErrNoRotation)
+
+	rotated := s.Tray()[0].Rotate()
+	placement, err := s.Play(Move{Slot: 0, Loc: Location{C: 2, R: 3}, Rotation: 1})
+	require.NoError(t, err)
+	require.Equal(t, rotated, placement.Piece)
+
+	plan, ok := s.Solve(ScoreEvaluator)
+	require.True(t, ok)
+	for i, slot := range plan.Slots {
+		_, err := s.Play(Move{Slot: slot, Loc: plan.Placements[i].Loc, Rotation: plan.Rotations[i]})
+		require.NoError(t, err)
+	}
+
+	replayed, err := Replay(s.Record())
+	require.NoError(t, err)
+	require.Equal(t, s.Board().Occupancy(), replayed.Board().Occupancy())
+	require.True(t, replayed.Config().PlayerRotation)
+}
+
+func TestLegalMovesScoreLikePlay(t *testing.T) {
+	config := DefaultConfig
+	config.Scoring = ScoringCombo
+	s, err := NewSession(5, config)
+	require.NoError(t, err)
+	for range 10 {
+		moves := s.LegalMoves()
+		if len(moves) == 0 {
+			break
+		}
+		move := moves[len(moves)/2]
+		placement, err := s.Play(move.Move)
+		require.NoError(t, err)
+		require.Equal(t, move.Placement, placement)
+	}
+}
@@ -0,0 +1,362 @@
+package lib
+
+import "slices"
+
+// Outcome is the state reached after playing part or all of a tray.
+type Outcome struct {
+	// Board is the board after the placements.  It is only valid for the duration of the evaluation.
+	Board *Board
+	// Placed is the number of tray pieces placed and Unplaced the number that didn't fit anywhere.
+	Placed   int
+	Unplaced int
+	Points   int
+	Lines    int
+
+	// orientations are the orientations of the pieces that can be dealt next, for Mobility.
+	orientations []orientation
+}
+
+// Mobility returns how many distinct orientations of the pieces that can be dealt next fit somewhere on the board.
+func (o Outcome) Mobility() int {
+	return o.Board.mobility(o.orientations)
+}
+
+// Evaluator rates an outcome.  The solver picks the plan whose outcome rates highest.
+type Evaluator func(outcome Outcome) float64
+
+// ScoreEvaluator maximizes the points scored by the tray, ignoring whether every piece was placed.
+func ScoreEvaluator(outcome Outcome) float64 {
+	return float64(outcome.Points)
+}
+
+// SurvivalEvaluator maximizes the number of pieces placed, then the number of distinct piece orientations that still
+// fit on the resulting board, then the number of empty cells.
+func SurvivalEvaluator(outcome Outcome) float64 {
+	return WeightedEvaluator(Weights{Placed: 1e6, Mobility: 1e3, EmptyCells: 1})(outcome)
+}
+
+// Weights configures WeightedEvaluator.
+type Weights struct {
+	Points     float64
+	Placed     float64
+	Lines      float64
+	EmptyCells float64
+	// Mobility is applied to the number of distinct orientations of the dealable pieces that fit on the resulting
+	// board.
+	Mobility float64
+}
+
+// WeightedEvaluator rates an outcome as a weighted sum of its features.
+func WeightedEvaluator(weights Weights) Evaluator {
+	return func(outcome Outcome) float64 {
+		value := weights.Points*float64(outcome.Points) +
+			weights.Placed*float64(outcome.Placed) +
+			weights.Lines*float64(outcome.Lines)
+		if weights.EmptyCells != 0 {
+			board := outcome.Board
+			emptyCells := board.Width()*board.Height() - board.Occupancy().Count()
+			value += weights.EmptyCells * float64(emptyCells)
+		}
+		if weights.Mobility != 0 {
+			value += weights.Mobility * float64(outcome.Mobility())
+		}
+		return value
+	}
+}
+
+// Mobility returns how many distinct orientations of the pieces fit somewhere on the board.
+func (b *Board) Mobility(pieces []Piece) int {
+	return b.mobility(b.orientations(pieces))
+}
+
+func (b *Board) mobility(orientations []orientation) int {
+	free := b.fullMask.AndNot(b.Occupancy())
+	var mobility int
+	for _, orientation := range orientations {
+		if orientation.fitsSomewhere(free) {
+			mobility++
+		}
+	}
+	return mobility
+}
+
+// orientation is a piece mask along with what's needed to test every location for it at once.
+type orientation struct {
+	mask PieceMask
+	// offsets are the bit indexes of the piece's cells relative to its top-left cell.
+	offsets []int
+	// anchors has a bit set for every top-left cell where the piece lies within the board.
+	anchors Bitboard
+}
+
+// fitsSomewhere reports whether the piece fits on a board with the given free cells.  A location works when every
+// piece cell is free, so shifting the free cells back by each cell's offset and intersecting leaves the locations
+// that work.
+func (o orientation) fitsSomewhere(free Bitboard) bool {
+	candidates := o.anchors
+	for _, offset := range o.offsets {
+		candidates = candidates.And(free.ShiftRight(offset))
+		if candidates.IsZero() {
+			return false
+		}
+	}
+	return true
+}
+
+// orientations returns every distinct rotation of the pieces for this board.
+func (b *Board) orientations(pieces []Piece) []orientation {
+	var orientations []orientation
+	seen := make(map[PieceMask]bool)
+	for _, piece := range pieces {
+		for _, rotation := range piece.Rotations() {
+			// Different pieces in a set can share a rotation, so dedupe across the set too.
+			mask := b.PieceMask(rotation)
+			// Orientations bigger than the board fit nowhere, and have no blocks to find offsets for.
+			if seen[mask] || mask.Width > b.width || mask.Height > b.height {
+				continue
+			}
+			seen[mask] = true
+			o := orientation{mask: mask}
+			for i := range mask.Height * b.width {
+				if mask.Bits.Has(i) {
+					o.offsets = append(o.offsets, i)
+				}
+			}
+			for r := 0; r+mask.Height <= b.height; r++ {
+				for c := 0; c+mask.Width <= b.width; c++ {
+					o.anchors.Set(r*b.width + c)
+				}
+			}
+			orientations = append(orientations, o)
+		}
+	}
+	return orientations
+}
+
+// Plan is a sequence of placements for the pieces in a tray.
+type Plan struct {
+	// Slots are the tray slots of the placed pieces, in the order they're placed.
+	Slots []int
+	// Placements are where each piece goes, in the same order as Slots.
+	Placements []PieceLocation
+	// Rotations are the quarter turns given to each piece before placing it, in the same order as Slots.  They're all
+	// zero unless the solve allowed rotation.
+	Rotations []int
+	// Outcome is the outcome of the plan.  Its Board is nil; Result holds the occupancy of the resulting board.
+	Outcome Outcome
+	Result  Bitboard
+	Value   float64
+}
+
+// Solve searches every order and location for the pieces in the tray, whose empty slots are nil, and returns the plan
+// rated highest by evaluate.  Placements are scored by the classic rules and mobility counts the classic pieces.
+// Pieces that fit nowhere once earlier pieces are placed are left out of the plan.  It returns false if no piece in the
+// tray fits on the board.
+func Solve(board *Board, tray []*Piece, evaluate Evaluator) (Plan, bool) {
+	return solve(board, nil, tray, false, AllPieces, ClassicScorer{}, 0, evaluate)
+}
+
+// solve is Solve with the choices a session makes.  colors are the colors of the board's cells, which are only
+// followed to score single-color lines if they're given.
+func solve(
+	board *Board, colors cellColors, tray []*Piece, rotate bool, pieces []Piece, scorer Scorer, streak int,
+	evaluate Evaluator,
+) (Plan, bool) {
+	s := solver{
+		board:        board.Clone(),
+		colors:       colors,
+		tray:         tray,
+		scorer:       scorer,
+		evaluate:     evaluate,
+		orientations: board.orientations(pieces),
+		used:         make([]bool, len(tray)),
+		choices:      make([][]solverChoice, len(tray)),
+		cache:        make(map[solverKey]float64),
+		visited:      make(map[solverKey]bool),
+	}
+	for i, piece := range tray {
+		if piece == nil {
+			s.used[i] = true
+			continue
+		}
+		rotated := *piece
+		for turns := range 4 {
+			if turns > 0 && !rotate {
+				break
+			}
+			mask := board.PieceMask(rotated)
+			if !slices.ContainsFunc(s.choices[i], func(c solverChoice) bool { return c.mask == mask }) {
+				s.choices[i] = append(s.choices[i], solverChoice{piece: rotated, mask: mask, turns: turns})
+			}
+			rotated = rotated.Rotate()
+		}
+	}
+	s.search(0, 0, streak, -1)
+	if !s.found {
+		return Plan{}, false
+	}
+	s.best.Outcome.Board = nil
+	s.best.Outcome.orientations = nil
+	return s.best, true
+}
+
+type solverKey struct {
+	occupancy               Bitboard
+	placed, unplaced, lines int
+	points, streak, after   int
+	used                    uint64
+	// colors is the hash of the cell colors when they're followed.
+	colors uint64
+}
+
+// solverChoice is an orientation a tray piece can be placed in.
+type solverChoice struct {
+	piece Piece
+	mask  PieceMask
+	turns int
+}
+
+type solver struct {
+	board Board
+	// colors are the colors of the board's cells as pieces are placed, or nil if they aren't followed.  They're
+	// replaced rather than changed, so taking back a placement restores the ones before it.
+	colors   cellColors
+	tray     []*Piece
+	scorer   Scorer
+	evaluate Evaluator
+	// orientations are computed once per solve rather than for every outcome evaluated.
+	orientations []orientation
+	used         []bool
+	// choices are the distinct orientations each tray piece can be placed in.
+	choices [][]solverChoice
+	cache   map[solverKey]float64
+	// visited holds the states already searched.  Placing pieces in a different order often reaches the same state,
+	// and searching it again can't find a better plan.
+	visited map[solverKey]bool
+
+	slots      []int
+	placements []PieceLocation
+	rotations  []int
+	best       Plan
+	found      bool
+}
+
+// search tries every placement of the unused pieces.  after is the slot of the last piece placed if it cleared no
+// lines, and -1 otherwise.  Two placements in a row that clear no lines give the same result in either order, so only
+// the order with the lower slot first is searched.
+func (s *solver) search(points, lines, streak, after int) {
+	if len(s.slots) > 0 && len(s.slots) < len(s.tray) {
+		key := solverKey{occupancy: s.board.Occupancy(), points: points, lines: lines, streak: streak, after: after}
+		if s.colors != nil {
+			key.colors = s.colors.hash()
+		}
+		for i, used := range s.used {
+			if used {
+				key.used |= 1 << uint(i)
+			}
+		}
+		if s.visited[key] {
+			return
+		}
+		s.visited[key] = true
+	}
+	// fitsAny is set if some unused piece fits, even if only in an order that isn't searched from here, since then
+	// this isn't where the plan ends.
+	var fitsAny bool
+	for i := range s.tray {
+		if s.used[i] {
+			continue
+		}
+		// Identical pieces lead to the same plans, so only try the first of them at each step.
+		duplicate := false
+		for j := range i {
+			if !s.used[j] && slices.EqualFunc(s.choices[j], s.choices[i], sameChoice) {
+				duplicate = true
+				break
+			}
+		}
+		if duplicate {
+			continue
+		}
+		for _, choice := range s.choices[i] {
+			for placement := range s.board.placements(choice.mask, choice.piece) {
+				fitsAny = true
+				nextStreak, nextAfter := 0, i
+				if placement.NumClearedLines() > 0 {
+					nextStreak, nextAfter = streak+1, -1
+				} else if i < after {
+					continue
+				}
+				var singleColorLines int
+				if s.colors != nil {
+					singleColorLines = s.colors.singleColorLines(&s.board, placement)
+				}
+				placement.score(s.scorer, choice.mask.NumBlocks, streak, singleColorLines)
+				s.used[i] = true
+				s.slots = append(s.slots, i)
+				s.placements = append(s.placements, placement.PieceLocation)
+				s.rotations = append(s.rotations, choice.turns)
+				s.board.Apply(placement)
+				colors := s.colors
+				if colors != nil {
+					s.colors = slices.Clone(colors)
+					s.colors.paint(&s.board, placement)
+				}
+				s.search(points+placement.Points, lines+placement.NumClearedLines(), nextStreak, nextAfter)
+				s.colors = colors
+				s.board.Undo()
+				s.rotations = s.rotations[:len(s.rotations)-1]
+				s.placements = s.placements[:len(s.placements)-1]
+				s.slots = s.slots[:len(s.slots)-1]
+				s.used[i] = false
+			}
+		}
+	}
+	if fitsAny || len(s.slots) == 0 {
+		return
+	}
+	unplaced := 0
+	for _, used := range s.used {
+		if !used {
+			unplaced++
+		}
+	}
+	outcome := Outcome{
+		Board:    &s.board,
+		Placed:   len(s.slots),
+		Unplaced: unplaced,
+		Points:   points,
+		Lines:    lines,
+
+		orientations: s.orientations,
+	}
+	key := solverKey{
+		occupancy: s.board.Occupancy(),
+		placed:    outcome.Placed,
+		unplaced:  outcome.Unplaced,
+		lines:     lines,
+		points:    points,
+	}
+	value, ok := s.cache[key]
+	if !ok {
+		value = s.evaluate(outcome)
+		s.cache[key] = value
+	}
+	if s.found && value <= s.best.Value {
+		return
+	}
+	s.found = true
+	s.best = Plan{
+		Slots:      append([]int(nil), s.slots...),
+		Placements: append([]PieceLocation(nil), s.placements...),
+		Rotations:  append([]int(nil), s.rotations...),
+		Outcome:    outcome,
+		Result:     key.occupancy,
+		Value:      value,
+	}
+}
+
+// sameChoice reports whether two orientations cover the same cells, whatever rotation produced them.
+func sameChoice(a, b solverChoice) bool {
+	return a.mask == b.mask
+}
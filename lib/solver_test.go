@@ -0,0 +1,84 @@
+package lib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSolveFindsClearingOrder(t *testing.T) {
+	board := NewBoard(4, 4)
+	_, _, ok := board.AddPiece(PieceLocation{Piece: parsePiece("###"), Loc: Location{C: 0, R: 0}})
+	require.True(t, ok)
+
+	single := parsePiece("#")
+	bar := parsePiece("####")
+	plan, ok := Solve(&board, []*Piece{&bar, nil, &single}, ScoreEvaluator)
+	require.True(t, ok)
+	// Completing the top row empties the board, and the bar then fills a whole row and empties it again.
+	require.Equal(t, []int{2, 0}, plan.Slots)
+	require.Equal(t, Location{C: 3, R: 0}, plan.Placements[0].Loc)
+	require.Equal(t, Location{C: 0, R: 0}, plan.Placements[1].Loc)
+	require.Equal(t, 2, plan.Outcome.Placed)
+	require.Equal(t, (1+10+300)+(4+10+300), plan.Outcome.Points)
+	require.True(t, plan.Result.IsZero())
+	require.Equal(t, "oooe\neeee\neeee\neeee\n", board.GetGrid().String())
+}
+
+func TestSolveReportsUnplacedPieces(t *testing.T) {
+	board := NewBoard(3, 3)
+	_, _, ok := board.AddPiece(PieceLocation{Piece: parsePiece("#"), Loc: Location{C: 0, R: 0}})
+	require.True(t, ok)
+	big := parsePiece("###\n###\n###")
+	square := parsePiece("##\n##")
+	plan, ok := Solve(&board, []*Piece{&big, &square}, SurvivalEvaluator)
+	require.True(t, ok)
+	require.Equal(t, 1, plan.Outcome.Placed)
+	require.Equal(t, 1, plan.Outcome.Unplaced)
+	require.Equal(t, []int{1}, plan.Slots)
+
+	_, ok = Solve(&board, []*Piece{nil, nil, nil}, SurvivalEvaluator)
+	require.False(t, ok)
+}
+
+func TestSolvePlacesEveryPieceThatFits(t *testing.T) {
+	// Placing the domino after the single is the order that isn't searched, which used to end the plan there as if the
+	// domino didn't fit.  Rating only empty cells prefers stopping after one piece if the plan can stop early.
+	board := NewBoard(DefaultBoardSize, DefaultBoardSize)
+	single := parsePiece("#")
+	domino := parsePiece("##")
+	plan, ok := Solve(&board, []*Piece{&single, &domino}, WeightedEvaluator(Weights{EmptyCells: 1}))
+	require.True(t, ok)
+	require.Equal(t, 2, plan.Outcome.Placed)
+	require.Equal(t, 0, plan.Outcome.Unplaced)
+}
+
+func TestSolveWithRotation(t *testing.T) {
+	board := NewBoard(1, 4)
+	bar := parsePiece("####")
+	_, ok := Solve(&board, []*Piece{&bar}, ScoreEvaluator)
+	require.False(t, ok)
+
+	plan, ok := solve(&board, nil, []*Piece{&bar}, true, AllPieces, ClassicScorer{}, 0, ScoreEvaluator)
+	require.True(t, ok)
+	require.Equal(t, []int{1}, plan.Rotations)
+	require.Equal(t, 4, plan.Placements[0].Piece.Height())
+	require.True(t, plan.Result.IsZero())
+}
+
+func BenchmarkSolveSurvival(b *testing.B) {
+	board := NewBoard(DefaultBoardSize, DefaultBoardSize)
+	tray := []*Piece{&AllPieces[2], &AllPieces[7], &AllPieces[10]}
+	for i := 0; i < b.N; i++ {
+		Solve(&board, tray, SurvivalEvaluator)
+	}
+}
+
+func BenchmarkSolveWithRotation(b *testing.B) {
+	board := NewBoard(DefaultBoardSize, DefaultBoardSize)
+	tray := []*Piece{&AllPieces[2], &AllPieces[7], &AllPieces[10]}
+	evaluate := WeightedEvaluator(Weights{Placed: 1e6, Lines: 1e3, EmptyCells: 1})
+	for i := 0; i < b.N; i++ {
+		solve(&board, nil, tray, true, AllPieces, ClassicScorer{}, 0, evaluate)
+	}
+}
@@ -2,7 +2,6 @@ package main
 
 import (
 	"log/slog"
-	"math/rand"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/mikecoop83/blocks/game"
@@ -14,17 +13,16 @@ func main() {
 	ebiten.SetWindowResizingMode(ebiten.WindowResizingModeEnabled)
 	ebiten.SetWindowSize(game.WindowWidth, game.WindowHeight)
 
-	gameID, err := getGameIDFromParams()
+	link, err := getLinkFromParams()
 	if err != nil {
-		slog.Error("unable to parse game ID", "error", err)
+		slog.Error("unable to parse game link", "error", err)
 	}
-	if gameID == 0 {
-		slog.Info("no game ID found, generating a new one")
-		gameID = rand.Uint64()
+	if link.GameID == 0 && link.Level == "" {
+		slog.Info("no game ID found, starting a new game")
 	}
 
 	// Run the game.
-	err = ebiten.RunGame(game.New(gameID, updateGameID))
+	err = ebiten.RunGame(game.New(link, updateLink))
 	if err != nil {
 		panic(err)
 	}
@@ -5,16 +5,20 @@ package main
 import (
 	"log/slog"
 	"os"
-	"strconv"
+	"strings"
+
+	"github.com/mikecoop83/blocks/game"
 )
 
-func getGameIDFromParams() (uint64, error) {
+// getLinkFromParams reads the game to play from the arguments, which can be a game link or just a game ID in words or
+// hex.  The words of an ID can be passed as separate arguments.
+func getLinkFromParams() (game.Link, error) {
 	if len(os.Args) < 2 {
-		return 0, nil
+		return game.Link{}, nil
 	}
-	return strconv.ParseUint(os.Args[1], 16, 64)
+	return game.ParseLink(strings.Join(os.Args[1:], " "))
 }
 
-func updateGameID(gameID uint64) {
-	slog.Info("updating game id", "id", strconv.FormatUint(gameID, 16))
+func updateLink(link game.Link) {
+	slog.Info("updating game link", "query", link.Query().Encode())
 }
@@ -0,0 +1,98 @@
+package persist
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"strings"
+)
+
+const (
+	// exportPrefix starts every export, naming its format.
+	exportPrefix = "blocks1:"
+	// maxExportSize bounds the size of an export's values once decompressed, so a short string can't expand to fill
+	// memory.  Real save data is a small fraction of it.
+	maxExportSize = 16 << 20
+)
+
+// ErrInvalidExport is returned when text isn't an export or was damaged on the way.
+var ErrInvalidExport = errors.New("invalid save data")
+
+// Export encodes the stored values whose keys are included as one string that can be copied between devices.  The
+// string is the prefix followed by the base64 of a CRC-32 of the values' JSON and the JSON compressed.
+func Export(include func(key string) bool) (string, error) {
+	keys, err := Keys()
+	if err != nil {
+		return "", err
+	}
+	values := make(map[string]string)
+	for _, key := range keys {
+		if !include(key) {
+			continue
+		}
+		values[key], err = Load(key)
+		if err != nil {
+			return "", err
+		}
+	}
+	data, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	return encodeExport(data)
+}
+
+// encodeExport checksums, compresses and encodes the JSON of exported values.
+func encodeExport(data []byte) (string, error) {
+	var buf bytes.Buffer
+	buf.Write(binary.BigEndian.AppendUint32(nil, crc32.ChecksumIEEE(data)))
+	w, err := flate.NewWriter(&buf, flate.BestCompression)
+	if err != nil {
+		return "", err
+	}
+	_, err = w.Write(data)
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		return "", err
+	}
+	return exportPrefix + base64.RawURLEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// DecodeExport checks text made by Export and returns its values in a Memory.
+func DecodeExport(text string) (*Memory, error) {
+	encoded, ok := strings.CutPrefix(strings.TrimSpace(text), exportPrefix)
+	if !ok {
+		return nil, fmt.Errorf("%w: doesn't start with %q", ErrInvalidExport, exportPrefix)
+	}
+	compressed, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(compressed) < 4 {
+		return nil, fmt.Errorf("%w: not base64", ErrInvalidExport)
+	}
+	data, err := io.ReadAll(io.LimitReader(flate.NewReader(bytes.NewReader(compressed[4:])), maxExportSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidExport, err)
+	}
+	if len(data) > maxExportSize {
+		return nil, fmt.Errorf("%w: more than %d bytes", ErrInvalidExport, maxExportSize)
+	}
+	if crc32.ChecksumIEEE(data) != binary.BigEndian.Uint32(compressed) {
+		return nil, fmt.Errorf("%w: checksum mismatch", ErrInvalidExport)
+	}
+	memory := NewMemory()
+	err = json.Unmarshal(data, &memory.values)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidExport, err)
+	}
+	if memory.values == nil {
+		return nil, fmt.Errorf("%w: no values", ErrInvalidExport)
+	}
+	return memory, nil
+}
@@ -0,0 +1,75 @@
+package persist
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Key names a value of type T, which is stored JSON-encoded.
+type Key[T any] string
+
+// Load decodes the value stored under the key.  It returns the zero value along with ErrNotFound if nothing is.
+func (k Key[T]) Load() (T, error) {
+	return k.LoadFrom(backend)
+}
+
+// LoadFrom decodes the value stored under the key in the backend rather than the one in use.
+func (k Key[T]) LoadFrom(b Backend) (T, error) {
+	var value T
+	data, err := b.Load(string(k))
+	if err != nil {
+		return value, err
+	}
+	err = json.Unmarshal([]byte(data), &value)
+	if err != nil {
+		return value, fmt.Errorf("decoding %s: %w", string(k), err)
+	}
+	return value, nil
+}
+
+func (k Key[T]) Store(value T) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("encoding %s: %w", string(k), err)
+	}
+	return Store(string(k), string(data))
+}
+
+func (k Key[T]) Delete() error {
+	return Delete(string(k))
+}
+
+// SchemaKey holds the version of the layout of the stored values, which is the version of the last migration run.
+const SchemaKey Key[int] = "schema"
+
+// Migration updates stored values from the layout of the previous schema version to that of Version.
+type Migration struct {
+	Version int
+	Migrate func() error
+}
+
+// Migrate runs the migrations newer than the stored schema version in order, recording the version after each one so
+// a failed migration is retried the next time without repeating the ones before it.  Nothing stored means schema
+// version 0.
+func Migrate(migrations []Migration) error {
+	version, err := SchemaKey.Load()
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	for _, migration := range migrations {
+		if migration.Version <= version {
+			continue
+		}
+		err := migration.Migrate()
+		if err != nil {
+			return fmt.Errorf("migrating to schema version %d: %w", migration.Version, err)
+		}
+		version = migration.Version
+		err = SchemaKey.Store(version)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+// Package persist stores the game's data between runs: in localStorage on the web and in files in the user's config
+// directory on desktop.
+package persist
+
+import (
+	"errors"
+	"slices"
+)
+
+// ErrNotFound is returned when nothing is stored under a key.
+var ErrNotFound = errors.New("not found")
+
+// Backend stores strings by key.  Keys must not contain "/".
+type Backend interface {
+	// Load returns the value stored under the key, or ErrNotFound.
+	Load(key string) (string, error)
+	Store(key string, value string) error
+	// Delete removes the key.  Deleting a key that isn't stored isn't an error.
+	Delete(key string) error
+	// Keys lists the stored keys in no particular order.
+	Keys() ([]string, error)
+}
+
+var backend = defaultBackend()
+
+// SetBackend replaces where values are stored and returns the previous backend, so tests can use a Memory.
+func SetBackend(b Backend) Backend {
+	previous := backend
+	backend = b
+	return previous
+}
+
+// Store stores the value under the key.
+func Store(key string, value string) error {
+	return backend.Store(key, value)
+}
+
+// Load returns the value stored under the key, or ErrNotFound.
+func Load(key string) (string, error) {
+	return backend.Load(key)
+}
+
+// Delete removes the key.
+func Delete(key string) error {
+	return backend.Delete(key)
+}
+
+// Keys lists the stored keys in sorted order.
+func Keys() ([]string, error) {
+	keys, err := backend.Keys()
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(keys)
+	return keys, nil
+}
+
+// Memory is a backend that keeps values in memory, for tests.
+type Memory struct {
+	values map[string]string
+}
+
+func NewMemory() *Memory {
+	return &Memory{values: make(map[string]string)}
+}
+
+func (m *Memory) Load(key string) (string, error) {
+	value, ok := m.values[key]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return value, nil
+}
+
+func (m *Memory) Store(key string, value string) error {
+	m.values[key] = value
+	return nil
+}
+
+func (m *Memory) Delete(key string) error {
+	delete(m.values, key)
+	return nil
+}
+
+func (m *Memory) Keys() ([]string, error) {
+	keys := make([]string, 0, len(m.values))
+	for key := range m.values {
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
@@ -4,17 +4,40 @@ package persist
 
 import "syscall/js"
 
-func Store(key string, value string) error {
-	localStorage := js.Global().Get("localStorage")
-	localStorage.Call("setItem", key, value)
+// localStorage stores values in the browser's localStorage.
+type localStorage struct{}
+
+func defaultBackend() Backend {
+	return localStorage{}
+}
+
+func (localStorage) storage() js.Value {
+	return js.Global().Get("localStorage")
+}
+
+func (s localStorage) Store(key string, value string) error {
+	s.storage().Call("setItem", key, value)
 	return nil
 }
 
-func Load(key string) (string, error) {
-	localStorage := js.Global().Get("localStorage")
-	val := localStorage.Call("getItem", key)
+func (s localStorage) Load(key string) (string, error) {
+	val := s.storage().Call("getItem", key)
 	if val.IsNull() {
-		return "", nil
+		return "", ErrNotFound
 	}
 	return val.String(), nil
 }
+
+func (s localStorage) Delete(key string) error {
+	s.storage().Call("removeItem", key)
+	return nil
+}
+
+func (s localStorage) Keys() ([]string, error) {
+	storage := s.storage()
+	keys := make([]string, storage.Get("length").Int())
+	for i := range keys {
+		keys[i] = storage.Call("key", i).String()
+	}
+	return keys, nil
+}
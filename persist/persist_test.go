@@ -0,0 +1,132 @@
+//go:build !js
+
+package persist
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackendsReportNotFound(t *testing.T) {
+	for name, b := range map[string]Backend{
+		"memory": NewMemory(),
+		"files":  Files{Dir: filepath.Join(t.TempDir(), "blocks")},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := b.Load("missing")
+			require.ErrorIs(t, err, ErrNotFound)
+			keys, err := b.Keys()
+			require.NoError(t, err)
+			require.Empty(t, keys)
+
+			require.NoError(t, b.Store("key", "first"))
+			require.NoError(t, b.Store("key", ""))
+			value, err := b.Load("key")
+			require.NoError(t, err)
+			require.Equal(t, "", value)
+			keys, err = b.Keys()
+			require.NoError(t, err)
+			require.Equal(t, []string{"key"}, keys)
+
+			require.NoError(t, b.Delete("key"))
+			require.NoError(t, b.Delete("key"))
+			_, err = b.Load("key")
+			require.ErrorIs(t, err, ErrNotFound)
+		})
+	}
+}
+
+func TestFilesLeavesNoTemporaryFiles(t *testing.T) {
+	dir := t.TempDir()
+	b := Files{Dir: dir}
+	require.NoError(t, b.Store("key", "value"))
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, "key", entries[0].Name())
+}
+
+func TestKey(t *testing.T) {
+	defer SetBackend(SetBackend(NewMemory()))
+	type record struct {
+		Score int64  `json:"score"`
+		Mode  string `json:"mode"`
+	}
+	key := Key[[]record]("records")
+	records, err := key.Load()
+	require.ErrorIs(t, err, ErrNotFound)
+	require.Nil(t, records)
+
+	require.NoError(t, key.Store([]record{{Score: 10, Mode: "daily"}}))
+	data, err := Load("records")
+	require.NoError(t, err)
+	require.Equal(t, `[{"score":10,"mode":"daily"}]`, data)
+	records, err = key.Load()
+	require.NoError(t, err)
+	require.Equal(t, []record{{Score: 10, Mode: "daily"}}, records)
+
+	require.NoError(t, Store("records", "not json"))
+	_, err = key.Load()
+	require.Error(t, err)
+	require.NotErrorIs(t, err, ErrNotFound)
+}
+
+func TestMigrate(t *testing.T) {
+	defer SetBackend(SetBackend(NewMemory()))
+	var ran []int
+	migration := func(version int) Migration {
+		return Migration{Version: version, Migrate: func() error {
+			ran = append(ran, version)
+			return nil
+		}}
+	}
+	require.NoError(t, Migrate([]Migration{migration(1), migration(2)}))
+	require.Equal(t, []int{1, 2}, ran)
+
+	// Only migrations newer than the stored version run.
+	ran = nil
+	require.NoError(t, Migrate([]Migration{migration(1), migration(2), migration(3)}))
+	require.Equal(t, []int{3}, ran)
+	version, err := SchemaKey.Load()
+	require.NoError(t, err)
+	require.Equal(t, 3, version)
+}
+
+func TestExport(t *testing.T) {
+	defer SetBackend(SetBackend(NewMemory()))
+	require.NoError(t, Store("scores", `[{"score":10}]`))
+	require.NoError(t, Store("setting", "on"))
+	require.NoError(t, Store("other", "not the game's"))
+
+	text, err := Export(func(key string) bool { return key != "other" })
+	require.NoError(t, err)
+	imported, err := DecodeExport(text + "\n")
+	require.NoError(t, err)
+	keys, err := imported.Keys()
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"scores", "setting"}, keys)
+	value, err := imported.Load("scores")
+	require.NoError(t, err)
+	require.Equal(t, `[{"score":10}]`, value)
+
+	_, err = DecodeExport("scores")
+	require.ErrorIs(t, err, ErrInvalidExport)
+	// Changing a character breaks the compressed data or the checksum.
+	damaged := []byte(text)
+	damaged[len(exportPrefix)+2] ^= 1
+	_, err = DecodeExport(string(damaged))
+	require.ErrorIs(t, err, ErrInvalidExport)
+
+	// Neither values that aren't a map nor ones too big to decompress are imported.
+	tooBig := `{"key":"` + strings.Repeat("a", maxExportSize) + `"}`
+	for _, data := range []string{"null", tooBig} {
+		text, err := encodeExport([]byte(data))
+		require.NoError(t, err)
+		_, err = DecodeExport(text)
+		require.ErrorIs(t, err, ErrInvalidExport)
+	}
+}
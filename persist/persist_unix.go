@@ -3,48 +3,98 @@
 package persist
 
 import (
+	"errors"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 const appName = "blocks"
 
-// getAppDataPath returns the path to the app's data directory.
-func getAppDataPath() (string, error) {
-	configDir, err := os.UserConfigDir() // Cross-platform config directory
-	if err != nil {
-		return "", err
-	}
+// tempPrefix starts the names of files being written, which aren't keys until they're renamed.
+const tempPrefix = ".tmp-"
 
-	appDataPath := filepath.Join(configDir, appName)
+// Files stores each value in a file named after its key in Dir, which is created when first written to.
+type Files struct {
+	Dir string
+}
 
-	// Create the directory if it doesn't exist
-	err = os.MkdirAll(appDataPath, os.ModePerm)
+func defaultBackend() Backend {
+	configDir, err := os.UserConfigDir() // Cross-platform config directory
 	if err != nil {
-		return "", err
+		return brokenBackend{err: err}
 	}
-
-	return appDataPath, nil
+	return Files{Dir: filepath.Join(configDir, appName)}
 }
 
-func Store(key string, value string) error {
-	path, err := getAppDataPath()
+// Store writes the value to a temporary file and renames it over the key's file, so a crash mid-write leaves the
+// previous value rather than a partial one.
+func (f Files) Store(key string, value string) error {
+	err := os.MkdirAll(f.Dir, 0o755)
+	if err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(f.Dir, tempPrefix+"*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	_, err = file.WriteString(value)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
 	if err != nil {
 		return err
 	}
-	filename := filepath.Join(path, key)
-	return os.WriteFile(filename, []byte(value), os.ModePerm)
+	return os.Rename(file.Name(), filepath.Join(f.Dir, key))
 }
 
-func Load(key string) (string, error) {
-	path, err := getAppDataPath()
-	if err != nil {
-		return "", err
+func (f Files) Load(key string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(f.Dir, key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", ErrNotFound
 	}
-	filename := filepath.Join(path, key)
-	data, err := os.ReadFile(filename)
 	if err != nil {
 		return "", err
 	}
 	return string(data), nil
 }
+
+func (f Files) Delete(key string) error {
+	err := os.Remove(filepath.Join(f.Dir, key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (f Files) Keys() ([]string, error) {
+	entries, err := os.ReadDir(f.Dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && !strings.HasPrefix(entry.Name(), tempPrefix) {
+			keys = append(keys, entry.Name())
+		}
+	}
+	return keys, nil
+}
+
+// brokenBackend fails every call with the error that kept the real backend from being set up.
+type brokenBackend struct {
+	err error
+}
+
+func (b brokenBackend) Load(string) (string, error) { return "", b.err }
+func (b brokenBackend) Store(string, string) error  { return b.err }
+func (b brokenBackend) Delete(string) error         { return b.err }
+func (b brokenBackend) Keys() ([]string, error)     { return nil, b.err }